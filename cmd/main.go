@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/aribhuiya/stormgate/internal/admin"
 	"github.com/aribhuiya/stormgate/internal/health_checker"
 	"github.com/aribhuiya/stormgate/internal/stormgate"
 	"github.com/aribhuiya/stormgate/internal/utils"
@@ -19,8 +20,12 @@ func main() {
 	}
 	log.Printf("\n 🌩️ Stormgate - A light weight High Performance L7 Load Balancer is starting...🚀\n Listening on %s port %d\n", cfg.Server.BindIp, cfg.Server.BindPort)
 
-	healthCheckerService := health_checker.NewHealthCheckerService(stormgateApp.Services)
+	healthCheckerService := health_checker.NewHealthCheckerService(stormgateApp.Services, cfg.HealthChecks)
 	healthCheckerService.StartService()
 
+	if cfg.Admin.BindPort != 0 {
+		go admin.NewServer(cfg.Admin, stormgateApp.Services, healthCheckerService).Serve()
+	}
+
 	stormgateApp.Serve()
 }
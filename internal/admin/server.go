@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/balancers"
+	"github.com/aribhuiya/stormgate/internal/health_checker"
+	"github.com/aribhuiya/stormgate/internal/stormgate"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// Server exposes read-only runtime state on a separate listener so it is never reachable through a routed path.
+type Server struct {
+	addr     string
+	services map[string]*stormgate.Service
+	health   HealthHistory
+	mux      *http.ServeMux
+}
+
+// HealthHistory supplies the recent health-check results served by GET /health/history.
+type HealthHistory interface {
+	History() map[string]map[string][]health_checker.Result
+}
+
+type serviceStatus struct {
+	Name       string          `json:"name"`
+	PathPrefix string          `json:"path_prefix"`
+	Strategy   string          `json:"strategy"`
+	Backends   []utils.Backend `json:"backends"`
+	Fallback   string          `json:"fallback_service,omitempty"`
+	Status     map[string]any  `json:"status,omitempty"`
+}
+
+// NewServer serves /health/history only when health is non-nil.
+func NewServer(cfg utils.Admin, services map[string]*stormgate.Service, health HealthHistory) *Server {
+	if cfg.BindIp == "" {
+		cfg.BindIp = "127.0.0.1"
+	}
+	s := &Server{
+		addr:     fmt.Sprintf("%s:%d", cfg.BindIp, cfg.BindPort),
+		services: services,
+		health:   health,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /backends", s.handleBackends)
+	if health != nil {
+		s.mux.HandleFunc("GET /health/history", s.handleHealthHistory)
+	}
+	return s
+}
+
+func (s *Server) Serve() {
+	log.Printf("Admin API listening on %s", s.addr)
+	err := http.ListenAndServe(s.addr, s.mux)
+	if err != nil {
+		log.Println("Admin API stopped:", err)
+	}
+}
+
+func (s *Server) handleBackends(w http.ResponseWriter, _ *http.Request) {
+	statuses := make([]serviceStatus, 0, len(s.services))
+	for _, svc := range s.services {
+		status := serviceStatus{
+			Name:       svc.Config.Name,
+			PathPrefix: svc.Config.PathPrefix,
+			Strategy:   svc.Config.Strategy,
+			Backends:   svc.Config.AllBackends(),
+			Fallback:   svc.Config.FallbackService,
+		}
+		if reporter, ok := svc.Balancer.(balancers.StatusReporter); ok {
+			status.Status = reporter.Status()
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statuses)
+}
+
+// handleHealthHistory returns the recent results of every backend by service name, or of one service with
+// ?service=<name>.
+func (s *Server) handleHealthHistory(w http.ResponseWriter, r *http.Request) {
+	history := s.health.History()
+	if name := r.URL.Query().Get("service"); name != "" {
+		backends, ok := history[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("no health history for service %q", name), http.StatusNotFound)
+			return
+		}
+		history = map[string]map[string][]health_checker.Result{name: backends}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(history)
+}
@@ -0,0 +1,206 @@
+package admin
+
+import (
+	"encoding/json"
+	"github.com/aribhuiya/stormgate/internal/health_checker"
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/stormgate"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeHistory map[string]map[string][]health_checker.Result
+
+func (f fakeHistory) History() map[string]map[string][]health_checker.Result {
+	return f
+}
+
+func get(s *Server, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestHandleBackends(t *testing.T) {
+	backends := utils.BackendsFromURLs("http://p1", "http://p2", "http://p3")
+	backends[2].Drain = true
+	services, err := stormgate.BuildServicesFromConfig([]utils.Service{
+		{Name: "web", PathPrefix: "/", Strategy: "round_robin", Backends: utils.BackendsFromURLs("http://w1")},
+		{
+			Name: "api", PathPrefix: "/api", Strategy: "round_robin", Backends: backends, PanicThreshold: 0.5,
+			OutlierDetection: &utils.OutlierDetectionConfig{ConsecutiveFailures: 1, BaseEjectionMs: 60000},
+		},
+	}, "")
+	if err != nil {
+		t.Fatalf("BuildServicesFromConfig() unexpected error: %v", err)
+	}
+	// p3 is drained, p2 is down and p1 gets ejected: nothing is left, so panic mode routes to p1 and p2.
+	api := services["/api"]
+	api.Observer.RequestFinished("http://p1", http_proxies.RequestResult{StatusCode: http.StatusBadGateway})
+	api.Balancer.SetHealthyBackends([]string{"http://p1"})
+
+	rec := get(NewServer(utils.Admin{}, services, nil), http.MethodGet, "/backends")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var got []struct {
+		Name     string          `json:"name"`
+		Strategy string          `json:"strategy"`
+		Backends []utils.Backend `json:"backends"`
+		Status   map[string]any  `json:"status"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	if len(got) != 2 || got[0].Name != "api" || got[1].Name != "web" {
+		t.Fatalf("services = %+v, want api and web sorted by name", got)
+	}
+	if got[1].Status != nil {
+		t.Errorf("web status = %v, want none for a bare round robin", got[1].Status)
+	}
+
+	status := got[0].Status
+	if !reflect.DeepEqual(got[0].Backends, backends) {
+		t.Errorf("api backends = %+v, want %+v", got[0].Backends, backends)
+	}
+	want := map[string]any{
+		"active_backends":     []any{"http://p1", "http://p2"},
+		"draining":            []any{"http://p3"},
+		"panic":               true,
+		"panic_entered_total": float64(1),
+		"healthy_fraction":    float64(0),
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(status[key], value) {
+			t.Errorf("status[%q] = %#v, want %#v", key, status[key], value)
+		}
+	}
+	ejected, ok := status["ejected"].(map[string]any)
+	if !ok || len(ejected) != 1 || ejected["http://p1"] == nil {
+		t.Errorf("status[\"ejected\"] = %#v, want only http://p1 with its return time", status["ejected"])
+	} else if until, err := time.Parse(time.RFC3339Nano, ejected["http://p1"].(string)); err != nil || !until.After(time.Now()) {
+		t.Errorf("http://p1 ejected until %v, want a time in the future", ejected["http://p1"])
+	}
+}
+
+func TestHandleHealthHistory(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	history := fakeHistory{
+		"api": {"http://a": {
+			{At: at, LatencyMs: 1.5, Passed: true, State: health_checker.STATE_HEALTHY},
+			{At: at.Add(time.Second), Passed: false, State: health_checker.STATE_UNHEALTHY, Error: "connection refused"},
+		}},
+		"web": {"http://w": {{At: at, Passed: true, State: health_checker.STATE_HEALTHY}}},
+	}
+	server := NewServer(utils.Admin{}, nil, history)
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		wantCode int
+		want     fakeHistory
+	}{
+		{name: "full history", method: http.MethodGet, target: "/health/history", wantCode: http.StatusOK, want: history},
+		{name: "one service", method: http.MethodGet, target: "/health/history?service=web", wantCode: http.StatusOK,
+			want: fakeHistory{"web": history["web"]}},
+		{name: "unknown service", method: http.MethodGet, target: "/health/history?service=nope", wantCode: http.StatusNotFound},
+		{name: "not a GET", method: http.MethodPost, target: "/health/history", wantCode: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(server, tt.method, tt.target)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (body %q)", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if tt.want == nil {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var got fakeHistory
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("history = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleHealthHistory_NotServedWithoutHealthChecks(t *testing.T) {
+	if rec := get(NewServer(utils.Admin{}, nil, nil), http.MethodGet, "/health/history"); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 without health checks", rec.Code)
+	}
+}
+
+// The ring buffer wraps after history_size results; the endpoint must still list them oldest first.
+func TestHandleHealthHistory_WrapsOldestFirst(t *testing.T) {
+	// Probe n answers 500+n for the first five probes, then the sixth hangs so the history stays put.
+	var probes atomic.Int32
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n := probes.Add(1); n <= 5 {
+			w.WriteHeader(500 + int(n))
+			return
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(backend.Close)
+
+	services, err := stormgate.BuildServicesFromConfig([]utils.Service{{
+		Name: "api", PathPrefix: "/api", Strategy: "round_robin",
+		Backends: utils.BackendsFromURLs(backend.URL),
+		Health:   &utils.HealthConfig{Type: "http", Endpoint: "health", Frequency: 1, TimeoutMs: 5000, HistorySize: 3},
+	}}, "")
+	if err != nil {
+		t.Fatalf("BuildServicesFromConfig() unexpected error: %v", err)
+	}
+	logTransitions := false
+	health := health_checker.NewHealthCheckerService(services, utils.HealthChecksConfig{Hooks: utils.HooksConfig{Log: &logTransitions}})
+	health.StartService()
+	t.Cleanup(func() {
+		close(release)
+		health.StopService()
+	})
+
+	wantLast := "unexpected status 505"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		results := health.History()["api"][backend.URL]
+		if len(results) > 0 && results[len(results)-1].Error == wantLast {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("history = %+v, want the fifth probe recorded", results)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := get(NewServer(utils.Admin{}, services, health), http.MethodGet, "/health/history?service=api")
+	var got fakeHistory
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	var errs []string
+	for _, r := range got["api"][backend.URL] {
+		errs = append(errs, r.Error)
+	}
+	want := []string{"unexpected status 503", "unexpected status 504", "unexpected status 505"}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("history errors = %v, want the last three probes oldest first %v", errs, want)
+	}
+}
@@ -2,6 +2,7 @@ package balancers
 
 import (
 	"errors"
+	"fmt"
 	"github.com/aribhuiya/stormgate/internal/balancers/consistent_hash"
 	"github.com/aribhuiya/stormgate/internal/utils"
 	"net/http"
@@ -13,7 +14,34 @@ type Balancer interface {
 	SetHealthyBackends(healthyBackends []string)
 }
 
+// StatusReporter is implemented by balancers that expose runtime state (e.g. the active tier) to the admin API.
+type StatusReporter interface {
+	Status() map[string]any
+}
+
+// innerStatus returns a copy of the wrapped balancer's status for decorators to extend.
+func innerStatus(inner Balancer) map[string]any {
+	status := map[string]any{}
+	if reporter, ok := inner.(StatusReporter); ok {
+		for k, v := range reporter.Status() {
+			status[k] = v
+		}
+	}
+	return status
+}
+
+// slowStartStrategies are the strategies that scale backend weights and so can ramp them with slow_start.
+var slowStartStrategies = map[string]bool{
+	"weighted_round_robin": true,
+	"weighted_random":      true,
+	"least_connections":    true,
+	"rendezvous_hash":      true,
+}
+
 func Create(name string, service *utils.Service) (Balancer, error) {
+	if service.SlowStart != nil && !slowStartStrategies[name] {
+		return nil, fmt.Errorf("slow_start is not supported by strategy %s; use weighted_round_robin, weighted_random, least_connections or rendezvous_hash", name)
+	}
 	switch name {
 	case "round_robin":
 		return NewRoundRobin(service)
@@ -21,8 +49,20 @@ func Create(name string, service *utils.Service) (Balancer, error) {
 		return NewRandomAutoSeed(service)
 	case "weighted_round_robin":
 		return NewWeightedRoundRobin(service)
+	case "weighted_random":
+		return NewWeightedRandom(service)
+	case "least_connections":
+		return NewLeastConnections(service)
+	case "p2c":
+		return NewPowerOfTwoChoices(service)
 	case "consistent_hash":
 		return consistent_hash.NewHashModulo(service)
+	case "maglev":
+		return consistent_hash.NewMaglev(service)
+	case "rendezvous_hash":
+		return consistent_hash.NewRendezvous(service)
+	case "consistent_hash_bounded":
+		return consistent_hash.NewBoundedLoad(service)
 	}
 
 	return nil, errors.New("unknown balancer type " + name)
@@ -0,0 +1,35 @@
+package balancers
+
+import (
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"testing"
+)
+
+func TestCreate_SlowStartNeedsWeightedStrategy(t *testing.T) {
+	tests := []struct {
+		strategy    string
+		expectError bool
+	}{
+		{strategy: "weighted_round_robin"},
+		{strategy: "weighted_random"},
+		{strategy: "least_connections"},
+		{strategy: "rendezvous_hash"},
+		{strategy: "round_robin", expectError: true},
+		{strategy: "random", expectError: true},
+		{strategy: "p2c", expectError: true},
+		{strategy: "consistent_hash", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			_, err := Create(tt.strategy, &utils.Service{
+				Backends:       []utils.Backend{{URL: "A", Weight: 1}, {URL: "B", Weight: 2}},
+				StrategyConfig: map[string]any{"source": "ip"},
+				SlowStart:      &utils.SlowStartConfig{DurationMs: 1000},
+			})
+			if (err != nil) != tt.expectError {
+				t.Errorf("Create(%s) error = %v, expectError %v", tt.strategy, err, tt.expectError)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package balancers
+
+import (
+	"context"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/cookies"
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+var stressStrategies = []string{
+	"round_robin", "random", "weighted_round_robin", "weighted_random", "least_connections", "p2c",
+	"consistent_hash", "maglev", "rendezvous_hash", "consistent_hash_bounded",
+}
+
+var stressHealthySets = [][]string{
+	{"A", "B", "C", "D", "E"},
+	{"A"},
+	{},
+	{"C", "E"},
+	{"E", "D", "C", "B"},
+	nil,
+	{"B", "D"},
+}
+
+func newStressService(strategy string) *utils.Service {
+	return &utils.Service{
+		Name:       strategy,
+		PathPrefix: "/",
+		Backends: []utils.Backend{
+			{URL: "A", Weight: 1}, {URL: "B", Weight: 2}, {URL: "C", Weight: 3, MaxConnections: 4},
+			{URL: "D", Weight: 1}, {URL: "E", Weight: 5, Backup: true},
+		},
+		StrategyConfig: map[string]any{"source": "ip", "table_size": 251},
+	}
+}
+
+// TestBalancers_ConcurrentHealthUpdates picks from every strategy while other goroutines keep replacing the
+// healthy set. Run with -race: picks must never see a torn backend set or return a backend that doesn't exist.
+func TestBalancers_ConcurrentHealthUpdates(t *testing.T) {
+	for _, strategy := range stressStrategies {
+		for _, slowStart := range []bool{false, true} {
+			if slowStart && !slowStartStrategies[strategy] {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s/slow_start=%v", strategy, slowStart), func(t *testing.T) {
+				service := newStressService(strategy)
+				if slowStart {
+					service.SlowStart = &utils.SlowStartConfig{DurationMs: 50}
+				}
+				b, err := Create(strategy, service)
+				if err != nil {
+					t.Fatalf("Create(%s) unexpected error: %v", strategy, err)
+				}
+				b, err = NewConnLimited(b, service)
+				if err != nil {
+					t.Fatalf("NewConnLimited() unexpected error: %v", err)
+				}
+				observer, _ := b.(http_proxies.RequestObserver)
+				tiers, err := NewTierPolicy(service)
+				if err != nil {
+					t.Fatalf("NewTierPolicy() unexpected error: %v", err)
+				}
+				b = NewFiltered(b, service.BackendURLs(), tiers)
+
+				stressBalancer(t, b, observer, 0)
+			})
+		}
+	}
+}
+
+// TestBalancers_ConcurrentHealthUpdatesUnwrapped runs the strategies without Filtered, whose mutex would
+// otherwise serialise the health updates, so each strategy's own copy-on-write swap races against itself.
+func TestBalancers_ConcurrentHealthUpdatesUnwrapped(t *testing.T) {
+	for _, strategy := range stressStrategies {
+		t.Run(strategy, func(t *testing.T) {
+			b, err := Create(strategy, newStressService(strategy))
+			if err != nil {
+				t.Fatalf("Create(%s) unexpected error: %v", strategy, err)
+			}
+			observer, _ := b.(http_proxies.RequestObserver)
+			stressBalancer(t, b, observer, 0)
+		})
+	}
+}
+
+// TestBalancers_ConcurrentStickyOutliers adds sticky cookies and outlier detection, fed with failing requests,
+// so ejections, restores and the Refresh they trigger run alongside picks and health updates.
+func TestBalancers_ConcurrentStickyOutliers(t *testing.T) {
+	for _, strategy := range stressStrategies {
+		t.Run(strategy, func(t *testing.T) {
+			service := newStressService(strategy)
+			service.StickyCookie = &utils.StickyCookieConfig{Secret: "stress"}
+			service.OutlierDetection = &utils.OutlierDetectionConfig{
+				ConsecutiveFailures: 2, BaseEjectionMs: 1, MaxEjectionMs: 5, MaxEjectionPercent: 50,
+			}
+			b, err := Create(strategy, service)
+			if err != nil {
+				t.Fatalf("Create(%s) unexpected error: %v", strategy, err)
+			}
+			b, err = NewStickyCookie(b, service)
+			if err != nil {
+				t.Fatalf("NewStickyCookie() unexpected error: %v", err)
+			}
+			b, err = NewConnLimited(b, service)
+			if err != nil {
+				t.Fatalf("NewConnLimited() unexpected error: %v", err)
+			}
+			observer, _ := b.(http_proxies.RequestObserver)
+			outliers, err := NewOutlierDetector(service, observer)
+			if err != nil {
+				t.Fatalf("NewOutlierDetector() unexpected error: %v", err)
+			}
+			filtered := NewFiltered(b, service.BackendURLs(), outliers)
+			outliers.OnChange(filtered.Refresh)
+
+			stressBalancer(t, filtered, outliers, 3)
+		})
+	}
+}
+
+// stressBalancer runs concurrent pickers against two goroutines cycling through the healthy sets. Each picker
+// sends back the sticky cookie it was last issued, and when failEvery is set every failEvery-th request it
+// reports to observer fails.
+func stressBalancer(t *testing.T, b Balancer, observer http_proxies.RequestObserver, failEvery int) {
+	t.Helper()
+	const pickers, picks, updaters = 8, 500, 2
+	valid := map[string]bool{"A": true, "B": true, "C": true, "D": true, "E": true}
+
+	done := make(chan struct{})
+	var updater sync.WaitGroup
+	for u := 0; u < updaters; u++ {
+		updater.Add(1)
+		go func(u int) {
+			defer updater.Done()
+			for i := u; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+					b.SetHealthyBackends(stressHealthySets[i%len(stressHealthySets)])
+				}
+			}
+		}(u)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, pickers)
+	for p := 0; p < pickers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			var sticky []*http.Cookie
+			for i := 0; i < picks; i++ {
+				req := &http.Request{Header: http.Header{}, RemoteAddr: fmt.Sprintf("10.0.%d.%d:1234", p, i%256)}
+				for _, cookie := range sticky {
+					req.AddCookie(cookie)
+				}
+				ctx, jar := cookies.NewContext(context.Background())
+				backend, err := b.PickBackend(req.WithContext(ctx))
+				if err != nil {
+					continue
+				}
+				if !valid[backend] {
+					errs <- fmt.Sprintf("PickBackend() = %q, not a configured backend", backend)
+					return
+				}
+				if issued := jar.Cookies(); len(issued) > 0 {
+					sticky = issued
+				}
+				if observer != nil {
+					result := http_proxies.RequestResult{StatusCode: http.StatusOK}
+					if failEvery > 0 && i%failEvery == 0 {
+						result.StatusCode = http.StatusServiceUnavailable
+					}
+					observer.RequestStarted(backend)
+					observer.RequestFinished(backend, result)
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+	close(done)
+	updater.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if reporter, ok := b.(StatusReporter); ok {
+		_ = reporter.Status()
+	}
+}
@@ -0,0 +1,100 @@
+package balancers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/balancers/inflight"
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+)
+
+// ConnLimited enforces the max_connections of each backend. A pick that lands on a backend at its limit is
+// retried a few times before the request is rejected; strategies that pick deterministically (the hashing
+// ones) keep returning the same backend, so their requests are rejected rather than moved elsewhere.
+// The limit is checked against requests the proxy has started, so concurrent picks may briefly overshoot it.
+type ConnLimited struct {
+	Balancer
+	observer http_proxies.RequestObserver // the wrapped strategy's observer, if any
+	limits   map[string]int64
+	inFlight *inflight.Counter
+	attempts int
+}
+
+// capacityAware is implemented by decorators that can route around a backend at its max_connections instead
+// of returning it again on every retry, such as StickyCookie with its pinned backend.
+type capacityAware interface {
+	setAtLimit(atLimit func(backend string) bool)
+}
+
+// NewConnLimited returns inner unchanged when no backend of the service sets max_connections.
+func NewConnLimited(inner Balancer, service *utils.Service) (Balancer, error) {
+	limits := map[string]int64{}
+	for _, b := range service.AllBackends() {
+		if b.MaxConnections < 0 {
+			return nil, fmt.Errorf("max_connections of backend %s must not be negative", b.URL)
+		}
+		if b.MaxConnections > 0 {
+			limits[b.URL] = b.MaxConnections
+		}
+	}
+	if len(limits) == 0 {
+		return inner, nil
+	}
+
+	backends := service.BackendURLs()
+	observer, _ := inner.(http_proxies.RequestObserver)
+	c := &ConnLimited{
+		Balancer: inner,
+		observer: observer,
+		limits:   limits,
+		inFlight: inflight.NewCounter(backends),
+		attempts: len(backends),
+	}
+	if aware, ok := inner.(capacityAware); ok {
+		aware.setAtLimit(c.atLimit)
+	}
+	return c, nil
+}
+
+func (c *ConnLimited) PickBackend(req *http.Request) (string, error) {
+	for i := 0; i < c.attempts; i++ {
+		backend, err := c.Balancer.PickBackend(req)
+		if err != nil {
+			return "", err
+		}
+		if !c.atLimit(backend) {
+			return backend, nil
+		}
+	}
+	return "", errors.New("backends are at max_connections")
+}
+
+func (c *ConnLimited) atLimit(backend string) bool {
+	limit, limited := c.limits[backend]
+	return limited && c.inFlight.Load(backend) >= limit
+}
+
+func (c *ConnLimited) RequestStarted(backend string) {
+	c.inFlight.Inc(backend)
+	if c.observer != nil {
+		c.observer.RequestStarted(backend)
+	}
+}
+
+func (c *ConnLimited) RequestFinished(backend string, result http_proxies.RequestResult) {
+	c.inFlight.Dec(backend)
+	if c.observer != nil {
+		c.observer.RequestFinished(backend, result)
+	}
+}
+
+func (c *ConnLimited) Status() map[string]any {
+	status := innerStatus(c.Balancer)
+	connections := make(map[string]int64, len(c.limits))
+	for backend := range c.limits {
+		connections[backend] = c.inFlight.Load(backend)
+	}
+	status["limited_connections"] = connections
+	return status
+}
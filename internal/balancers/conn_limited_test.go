@@ -0,0 +1,54 @@
+package balancers
+
+import (
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"testing"
+)
+
+func TestConnLimited(t *testing.T) {
+	service := &utils.Service{Backends: []utils.Backend{{URL: "A", MaxConnections: 1}, {URL: "B", MaxConnections: 2}}}
+	inner, err := NewRoundRobin(service)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := NewConnLimited(inner, service)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	limited := b.(*ConnLimited)
+
+	limited.RequestStarted("A")
+	for i := 0; i < 4; i++ {
+		if got, _ := limited.PickBackend(nil); got != "B" {
+			t.Fatalf("PickBackend() = %v, want B while A is full", got)
+		}
+	}
+
+	limited.RequestStarted("B")
+	limited.RequestStarted("B")
+	if _, err := limited.PickBackend(nil); err == nil {
+		t.Errorf("expected error when every backend is full")
+	}
+
+	limited.RequestFinished("A", http_proxies.RequestResult{StatusCode: 200})
+	if got, err := limited.PickBackend(nil); err != nil || got != "A" {
+		t.Errorf("PickBackend() = %v, %v, want A after it freed up", got, err)
+	}
+}
+
+func TestNewConnLimited_Unlimited(t *testing.T) {
+	service := &utils.Service{Backends: utils.BackendsFromURLs("A", "B")}
+	inner, err := NewRoundRobin(service)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b, err := NewConnLimited(inner, service); err != nil || b != Balancer(inner) {
+		t.Errorf("NewConnLimited() = %v, %v, want the inner balancer unchanged", b, err)
+	}
+
+	service.Backends[0].MaxConnections = -1
+	if _, err := NewConnLimited(inner, service); err == nil {
+		t.Errorf("expected error for negative max_connections")
+	}
+}
@@ -0,0 +1,162 @@
+package consistent_hash
+
+import (
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/balancers/inflight"
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+const (
+	DefaultLoadFactor   = 0.25
+	DefaultVirtualNodes = 100
+)
+
+// BoundedLoad is consistent hashing with bounded loads (Mirrokni, Thorup, Zadimoghaddam): a key goes to the
+// first backend clockwise on the ring whose in-flight load is below ceil((1+ε) * average), so a hot key spills
+// over to its ring neighbours instead of overloading one backend.
+//
+// Loads are counted by the proxy through RequestStarted/RequestFinished, so the bound is approximate while
+// concurrent picks race ahead of the increments.
+type BoundedLoad struct {
+	service      *utils.Service
+	source       hashSource
+	fallbacks    []hashSource
+	loadFactor   float64
+	virtualNodes int
+	inFlight     *inflight.Counter
+	ring         atomic.Pointer[hashRing]
+}
+
+type hashRing struct {
+	backends []string
+	points   []ringPoint // sorted by hash
+}
+
+type ringPoint struct {
+	hash    uint64
+	backend int // index into hashRing.backends
+}
+
+func NewBoundedLoad(service *utils.Service) (*BoundedLoad, error) {
+	backends := service.BackendURLs()
+	if len(backends) == 0 {
+		err := errors.New("no available backends")
+		return nil, err
+	}
+	hashKeySource, fallbacks, err := newHashSources(service)
+	if err != nil {
+		return nil, err
+	}
+
+	loadFactor := DefaultLoadFactor
+	if raw, exists := service.StrategyConfig["load_factor"]; exists {
+		switch val := raw.(type) {
+		case float64:
+			loadFactor = val
+		case int:
+			loadFactor = float64(val)
+		default:
+			return nil, errors.New("load_factor must be a number")
+		}
+		if loadFactor <= 0 {
+			return nil, errors.New("load_factor must be greater than 0")
+		}
+	}
+
+	virtualNodes := DefaultVirtualNodes
+	if raw, exists := service.StrategyConfig["virtual_nodes"]; exists {
+		val, ok := raw.(int)
+		if !ok || val <= 0 {
+			return nil, errors.New("virtual_nodes must be a positive integer")
+		}
+		virtualNodes = val
+	}
+
+	b := &BoundedLoad{
+		service:      service,
+		source:       hashKeySource,
+		fallbacks:    fallbacks,
+		loadFactor:   loadFactor,
+		virtualNodes: virtualNodes,
+		inFlight:     inflight.NewCounter(backends),
+	}
+	b.ring.Store(buildHashRing(backends, virtualNodes))
+	return b, nil
+}
+
+func (b *BoundedLoad) PickBackend(req *http.Request) (string, error) {
+	ring := b.ring.Load()
+	if len(ring.backends) == 0 {
+		return "", utils.ErrNoHealthyBackends
+	}
+	key, err := deriveKey(req, b.source, b.fallbacks)
+	if err != nil {
+		return "", err
+	}
+
+	capacity := b.capacity(ring.backends)
+	hash := hashString(key)
+	start := sort.Search(len(ring.points), func(i int) bool { return ring.points[i].hash >= hash })
+
+	visited := make([]bool, len(ring.backends))
+	remaining := len(ring.backends)
+	for i := 0; i < len(ring.points) && remaining > 0; i++ {
+		point := ring.points[(start+i)%len(ring.points)]
+		if visited[point.backend] {
+			continue
+		}
+		visited[point.backend] = true
+		remaining--
+		backend := ring.backends[point.backend]
+		if b.inFlight.Load(backend) < capacity {
+			return backend, nil
+		}
+	}
+
+	// Every backend is at capacity, which only happens transiently; keep the key on its home backend.
+	return ring.backends[ring.points[start%len(ring.points)].backend], nil
+}
+
+// capacity is the per-backend in-flight limit ceil((1+ε) * (total+1) / n), counting the request being placed.
+func (b *BoundedLoad) capacity(backends []string) int64 {
+	total := b.inFlight.Total(backends) + 1
+	return int64(math.Ceil((1 + b.loadFactor) * float64(total) / float64(len(backends))))
+}
+
+func (b *BoundedLoad) SetHealthyBackends(healthyBackends []string) {
+	if hasChanged := utils.HasBackendChanged(b.ring.Load().backends, healthyBackends); !hasChanged {
+		return
+	}
+	b.ring.Store(buildHashRing(healthyBackends, b.virtualNodes))
+}
+
+func (b *BoundedLoad) RequestStarted(backend string) {
+	b.inFlight.Inc(backend)
+}
+
+func (b *BoundedLoad) RequestFinished(backend string, _ http_proxies.RequestResult) {
+	b.inFlight.Dec(backend)
+}
+
+func buildHashRing(backends []string, virtualNodes int) *hashRing {
+	ring := &hashRing{
+		backends: append([]string(nil), backends...),
+		points:   make([]ringPoint, 0, len(backends)*virtualNodes),
+	}
+	for i, backend := range ring.backends {
+		for v := 0; v < virtualNodes; v++ {
+			ring.points = append(ring.points, ringPoint{
+				hash:    hashString(backend + "#" + strconv.Itoa(v)),
+				backend: i,
+			})
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i].hash < ring.points[j].hash })
+	return ring
+}
@@ -0,0 +1,126 @@
+package consistent_hash
+
+import (
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestNewBoundedLoad_Config(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      map[string]any
+		expectError bool
+	}{
+		{name: "defaults", config: map[string]any{"source": "ip"}},
+		{name: "float load factor", config: map[string]any{"source": "ip", "load_factor": 0.5}},
+		{name: "int load factor", config: map[string]any{"source": "ip", "load_factor": 1}},
+		{name: "custom virtual nodes", config: map[string]any{"source": "ip", "virtual_nodes": 10}},
+		{name: "negative load factor", config: map[string]any{"source": "ip", "load_factor": -0.1}, expectError: true},
+		{name: "malformed load factor", config: map[string]any{"source": "ip", "load_factor": "high"}, expectError: true},
+		{name: "zero virtual nodes", config: map[string]any{"source": "ip", "virtual_nodes": 0}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewBoundedLoad(&utils.Service{Backends: utils.BackendsFromURLs("A", "B"), StrategyConfig: tt.config})
+			if (err != nil) != tt.expectError {
+				t.Errorf("NewBoundedLoad() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestBoundedLoad_StickyWhenIdle(t *testing.T) {
+	b, err := NewBoundedLoad(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B", "C"),
+		StrategyConfig: map[string]any{"source": "header", "key": "X-User-ID"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := hotKeyRequest()
+
+	first, _ := b.PickBackend(req)
+	for i := 0; i < 20; i++ {
+		got, err := b.PickBackend(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != first {
+			t.Fatalf("call %d: got %s, want %s", i, got, first)
+		}
+		b.RequestStarted(got)
+		b.RequestFinished(got, http_proxies.RequestResult{})
+	}
+}
+
+func TestBoundedLoad_HotKeySpillsOver(t *testing.T) {
+	backends := []string{"A", "B", "C"}
+	b, err := NewBoundedLoad(&utils.Service{
+		Backends:       utils.BackendsFromURLs(backends...),
+		StrategyConfig: map[string]any{"source": "header", "key": "X-User-ID", "load_factor": 0.25},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := hotKeyRequest()
+
+	// Hold every request open so the hot key keeps adding load.
+	const requests = 30
+	for i := 1; i <= requests; i++ {
+		got, err := b.PickBackend(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		b.RequestStarted(got)
+
+		bound := int64(math.Ceil(1.25 * float64(i) / float64(len(backends))))
+		for _, backend := range backends {
+			if load := b.inFlight.Load(backend); load > bound {
+				t.Fatalf("after %d requests backend %s has %d in flight, bound is %d", i, backend, load, bound)
+			}
+		}
+	}
+	for _, backend := range backends {
+		if b.inFlight.Load(backend) == 0 {
+			t.Errorf("backend %s received no spill-over traffic", backend)
+		}
+	}
+}
+
+func TestBoundedLoad_SkipsUnhealthyBackends(t *testing.T) {
+	b, err := NewBoundedLoad(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B", "C"),
+		StrategyConfig: map[string]any{"source": "header", "key": "X-User-ID"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := hotKeyRequest()
+	home, _ := b.PickBackend(req)
+
+	var healthy []string
+	for _, backend := range []string{"A", "B", "C"} {
+		if backend != home {
+			healthy = append(healthy, backend)
+		}
+	}
+	b.SetHealthyBackends(healthy)
+	if got, _ := b.PickBackend(req); got == home {
+		t.Errorf("picked unhealthy backend %s", got)
+	}
+
+	b.SetHealthyBackends(nil)
+	if _, err := b.PickBackend(req); err == nil {
+		t.Errorf("expected error with no healthy backends")
+	}
+}
+
+func hotKeyRequest() *http.Request {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-User-ID", "celebrity")
+	return req
+}
@@ -0,0 +1,52 @@
+package consistent_hash
+
+import (
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+	"strings"
+)
+
+const DefaultCompositeSeparator = "|"
+
+// compositeSource joins the keys of several sources, e.g. tenant header and user id. If any part is missing
+// the whole key is, so the fallback chain applies instead of hashing a partial key.
+type compositeSource struct {
+	parts     []hashSource
+	separator string
+}
+
+func NewCompositeSource(service *utils.Service) (*compositeSource, error) {
+	raw, exists := service.StrategyConfig["sources"]
+	if !exists {
+		return nil, errors.New("sources is required for source composite")
+	}
+	parts, err := subSources(service, raw, "sources")
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("sources must not be empty")
+	}
+
+	separator := DefaultCompositeSeparator
+	if rawSep, exists := service.StrategyConfig["separator"]; exists {
+		sep, ok := rawSep.(string)
+		if !ok {
+			return nil, errors.New("separator must be a string")
+		}
+		separator = sep
+	}
+	return &compositeSource{parts: parts, separator: separator}, nil
+}
+
+func (c *compositeSource) getSource(req *http.Request) string {
+	keys := make([]string, len(c.parts))
+	for i, part := range c.parts {
+		keys[i] = part.getSource(req)
+		if keys[i] == "" {
+			return ""
+		}
+	}
+	return strings.Join(keys, c.separator)
+}
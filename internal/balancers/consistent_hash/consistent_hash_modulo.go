@@ -2,16 +2,19 @@ package consistent_hash
 
 import (
 	"errors"
+	"fmt"
 	"github.com/aribhuiya/stormgate/internal/utils"
 	"github.com/cespare/xxhash/v2"
 	"net/http"
 	"strings"
+	"sync/atomic"
 )
 
 type HashModulo struct {
-	service            *utils.Service
-	source             hashSource
-	fallbackToIpSource *ipSource
+	service   *utils.Service
+	backends  atomic.Pointer[[]string] // healthy backends, replaced as a whole on every health change
+	source    hashSource
+	fallbacks []hashSource
 }
 
 type hashSource interface {
@@ -19,82 +22,154 @@ type hashSource interface {
 }
 
 const (
-	SOURCE_IP     = "IP"
-	SOURCE_HEADER = "HEADER"
-	SOURCE_COOKIE = "COOKIE"
+	SOURCE_IP           = "IP"
+	SOURCE_HEADER       = "HEADER"
+	SOURCE_COOKIE       = "COOKIE"
+	SOURCE_QUERY        = "QUERY"
+	SOURCE_PATH_SEGMENT = "PATH_SEGMENT"
+	SOURCE_HOST         = "HOST"
+	SOURCE_COMPOSITE    = "COMPOSITE"
 )
 
 func NewHashModulo(service *utils.Service) (*HashModulo, error) {
-	if len(service.Backends) == 0 {
+	backends := service.BackendURLs()
+	if len(backends) == 0 {
 		err := errors.New("no available backends")
 		return nil, err
 	}
-	source, ok := service.StrategyConfig["source"].(string)
-	if !ok {
-		return nil, errors.New("source not defined for consistent_hash")
+	hashKeySource, fallbacks, err := newHashSources(service)
+	if err != nil {
+		return nil, err
 	}
-	var hashKeySource hashSource
-	var err error
-	switch strings.ToUpper(source) {
-	case SOURCE_IP:
-		hashKeySource = NewIPSource(service)
-	case SOURCE_HEADER:
-		hashKeySource, err = NewHeaderSource(service)
-		if err != nil {
-			return nil, err
-		}
-	case SOURCE_COOKIE:
-		hashKeySource, err = NewCookieSource(service)
+
+	h := &HashModulo{
+		service:   service,
+		source:    hashKeySource,
+		fallbacks: fallbacks,
+	}
+	h.backends.Store(&backends)
+	return h, nil
+}
+
+// newHashSources builds the configured key source and the ordered fallback chain consulted when it yields no
+// key: every entry of strategy_config.fallback, then the IP source when fallback_to_ip is set.
+// Shared by every hashing balancer in this package.
+func newHashSources(service *utils.Service) (hashSource, []hashSource, error) {
+	hashKeySource, err := newHashSource(service)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var fallbacks []hashSource
+	if raw, exists := service.StrategyConfig["fallback"]; exists {
+		fallbacks, err = subSources(service, raw, "fallback")
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
-
-	var fallbackToIP *ipSource = nil
 	fallbackToIPRaw, exists := service.StrategyConfig["fallback_to_ip"]
 	if exists {
 		val, ok := fallbackToIPRaw.(bool)
 		if !ok {
-			return nil, errors.New("fallback_to_ip value must be a true/false")
+			return nil, nil, errors.New("fallback_to_ip value must be a true/false")
 		}
 		if val {
-			fallbackToIP = NewIPSource(service)
+			fallbacks = append(fallbacks, NewIPSource(service))
 		}
 	}
-
-	return &HashModulo{
-		service:            service,
-		source:             hashKeySource,
-		fallbackToIpSource: fallbackToIP,
-	}, nil
+	return hashKeySource, fallbacks, nil
 }
 
-func hashString(s string) uint64 {
-	return xxhash.Sum64String(s)
+// newHashSource builds the source described by service.StrategyConfig["source"] and its sibling keys.
+func newHashSource(service *utils.Service) (hashSource, error) {
+	source, ok := service.StrategyConfig["source"].(string)
+	if !ok {
+		return nil, errors.New("source not defined in strategy_config")
+	}
+	switch strings.ToUpper(source) {
+	case SOURCE_IP:
+		return NewIPSource(service), nil
+	case SOURCE_HEADER:
+		return NewHeaderSource(service)
+	case SOURCE_COOKIE:
+		return NewCookieSource(service)
+	case SOURCE_QUERY:
+		return NewQuerySource(service)
+	case SOURCE_PATH_SEGMENT:
+		return NewPathSegmentSource(service)
+	case SOURCE_HOST:
+		return NewHostSource(service), nil
+	case SOURCE_COMPOSITE:
+		return NewCompositeSource(service)
+	default:
+		return nil, errors.New("unknown hash source " + source)
+	}
 }
 
-func (h *HashModulo) PickBackend(req *http.Request) (string, error) {
-	if len(h.service.Backends) == 0 {
-		return "", errors.New("no healthy backends")
+// subSources builds a list of nested source definitions, each a map with the same keys as strategy_config
+// ("source", "key", ...).
+func subSources(service *utils.Service, raw any, field string) ([]hashSource, error) {
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of sources", field)
 	}
-	key := h.source.getSource(req)
+	sources := make([]hashSource, 0, len(list))
+	for i, item := range list {
+		cfg, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s entry %d must be a map with a source", field, i)
+		}
+		sub := *service
+		sub.StrategyConfig = cfg
+		source, err := newHashSource(&sub)
+		if err != nil {
+			return nil, fmt.Errorf("%s entry %d: %w", field, i, err)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
 
-	if key == "" && h.fallbackToIpSource != nil {
-		key = h.fallbackToIpSource.getSource(req)
+// deriveKey returns the hashing key for req from the first source, in order, that yields one.
+func deriveKey(req *http.Request, source hashSource, fallbacks []hashSource) (string, error) {
+	key := source.getSource(req)
+
+	for _, fallback := range fallbacks {
+		if key != "" {
+			break
+		}
+		key = fallback.getSource(req)
 	}
 
 	if key == "" {
 		return "", errors.New("unable to derive key for hashing")
 	}
+	return key, nil
+}
+
+func hashString(s string) uint64 {
+	return xxhash.Sum64String(s)
+}
+
+func (h *HashModulo) PickBackend(req *http.Request) (string, error) {
+	backends := *h.backends.Load()
+	if len(backends) == 0 {
+		return "", utils.ErrNoHealthyBackends
+	}
+	key, err := deriveKey(req, h.source, h.fallbacks)
+	if err != nil {
+		return "", err
+	}
 
 	hash := hashString(key)
-	index := int(hash % uint64(len(h.service.Backends)))
-	return h.service.Backends[index], nil
+	index := int(hash % uint64(len(backends)))
+	return backends[index], nil
 }
 
 func (h *HashModulo) SetHealthyBackends(healthyBackends []string) {
-	if hasChanged := utils.HasBackendChanged(h.service.Backends, healthyBackends); !hasChanged {
+	if hasChanged := utils.HasBackendChanged(*h.backends.Load(), healthyBackends); !hasChanged {
 		return
 	}
-	h.service.Backends = healthyBackends
+	backends := append([]string(nil), healthyBackends...)
+	h.backends.Store(&backends)
 }
@@ -18,7 +18,7 @@ func TestHashModulo_PickBackend(t *testing.T) {
 		{
 			name: "IP-based hashing",
 			service: &utils.Service{
-				Backends: []string{"A", "B"},
+				Backends: utils.BackendsFromURLs("A", "B"),
 				StrategyConfig: map[string]any{
 					"source": "ip",
 				},
@@ -30,7 +30,7 @@ func TestHashModulo_PickBackend(t *testing.T) {
 		{
 			name: "Header-based hashing with valid header",
 			service: &utils.Service{
-				Backends: []string{"A", "B"},
+				Backends: utils.BackendsFromURLs("A", "B"),
 				StrategyConfig: map[string]any{
 					"source": "header",
 					"key":    "X-User-ID",
@@ -47,7 +47,7 @@ func TestHashModulo_PickBackend(t *testing.T) {
 		{
 			name: "Header-based missing header with fallback to IP",
 			service: &utils.Service{
-				Backends: []string{"A", "B"},
+				Backends: utils.BackendsFromURLs("A", "B"),
 				StrategyConfig: map[string]any{
 					"source":         "header",
 					"key":            "X-User-ID",
@@ -61,7 +61,7 @@ func TestHashModulo_PickBackend(t *testing.T) {
 		{
 			name: "Missing key and no fallback",
 			service: &utils.Service{
-				Backends: []string{"A", "B"},
+				Backends: utils.BackendsFromURLs("A", "B"),
 				StrategyConfig: map[string]any{
 					"source": "header",
 					"key":    "missing",
@@ -74,7 +74,7 @@ func TestHashModulo_PickBackend(t *testing.T) {
 		{
 			name: "Cookie-based hashing - plain value",
 			service: &utils.Service{
-				Backends: []string{"A", "B"},
+				Backends: utils.BackendsFromURLs("A", "B"),
 				StrategyConfig: map[string]any{
 					"source": "cookie",
 					"name":   "session_id",
@@ -91,7 +91,7 @@ func TestHashModulo_PickBackend(t *testing.T) {
 		{
 			name: "Cookie-based hashing - JSON with key",
 			service: &utils.Service{
-				Backends: []string{"A", "B"},
+				Backends: utils.BackendsFromURLs("A", "B"),
 				StrategyConfig: map[string]any{
 					"source":     "cookie",
 					"name":       "user",
@@ -109,7 +109,7 @@ func TestHashModulo_PickBackend(t *testing.T) {
 		{
 			name: "Cookie missing - fallback to IP",
 			service: &utils.Service{
-				Backends: []string{"A", "B"},
+				Backends: utils.BackendsFromURLs("A", "B"),
 				StrategyConfig: map[string]any{
 					"source":         "cookie",
 					"name":           "missing",
@@ -123,7 +123,7 @@ func TestHashModulo_PickBackend(t *testing.T) {
 		{
 			name: "Cookie missing - no fallback",
 			service: &utils.Service{
-				Backends: []string{"A", "B"},
+				Backends: utils.BackendsFromURLs("A", "B"),
 				StrategyConfig: map[string]any{
 					"source": "cookie",
 					"name":   "missing",
@@ -136,7 +136,7 @@ func TestHashModulo_PickBackend(t *testing.T) {
 		{
 			name: "Invalid source type",
 			service: &utils.Service{
-				Backends: []string{"A", "B"},
+				Backends: utils.BackendsFromURLs("A", "B"),
 				StrategyConfig: map[string]any{
 					"source": 123,
 				},
@@ -148,7 +148,7 @@ func TestHashModulo_PickBackend(t *testing.T) {
 		{
 			name: "Missing source config",
 			service: &utils.Service{
-				Backends:       []string{"A", "B"},
+				Backends:       utils.BackendsFromURLs("A", "B"),
 				StrategyConfig: map[string]any{},
 			},
 			request:       &http.Request{},
@@ -158,7 +158,7 @@ func TestHashModulo_PickBackend(t *testing.T) {
 		{
 			name: "Header based hashing",
 			service: &utils.Service{
-				Backends: []string{"A", "B"},
+				Backends: utils.BackendsFromURLs("A", "B"),
 				StrategyConfig: map[string]any{
 					"source": "header",
 					"key":    "X-User-ID",
@@ -1,39 +1,44 @@
 package consistent_hash
 
 import (
-	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/aribhuiya/stormgate/internal/cookies"
 	"github.com/aribhuiya/stormgate/internal/utils"
 	"github.com/google/uuid"
 	"net/http"
+	"time"
+)
+
+const (
+	DefaultHashCookieName   = "stormgate-id"
+	DefaultHashCookieMaxAge = 365 * 24 * time.Hour
 )
 
 type cookie_source struct {
-	cookieName      string
+	cookie          *cookies.Spec
 	cookieKey       string
 	injectIfMissing bool
 }
 
 func (c *cookie_source) getSource(req *http.Request) string {
-	cookie, err := req.Cookie(c.cookieName)
-	if err != nil {
+	value, ok := c.cookie.Read(req)
+	if !ok {
 		if c.injectIfMissing {
 			cookieVal := generateUUID()
-			ctx := context.WithValue(req.Context(), "inject_cookie", cookieVal)
-			*req = *req.WithContext(ctx) // mutate the *req object in place
+			c.cookie.Issue(req, cookieVal)
 			return cookieVal
 		}
 		return ""
 	}
 
 	if c.cookieKey == "" {
-		return cookie.Value
+		return value
 	}
 
-	decoded, err := base64.StdEncoding.DecodeString(cookie.Value)
+	decoded, err := base64.StdEncoding.DecodeString(value)
 	if err != nil {
 		return ""
 	}
@@ -69,11 +74,16 @@ func NewCookieSource(service *utils.Service) (*cookie_source, error) {
 		injectIfMissing = val
 	}
 
-	name, ok := service.StrategyConfig["name"].(string)
-	if !ok && !injectIfMissing {
+	cfg, err := cookieConfig(service.StrategyConfig)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Name == "" && !injectIfMissing {
 		return nil, errors.New("cookie name is required or use inject_if_missing")
-	} else if !ok {
-		name = "stormgate-id" //TODO: Use a config driven id for better layering
+	}
+	spec, err := cookies.NewSpec(cfg, DefaultHashCookieName, service.PathPrefix, DefaultHashCookieMaxAge)
+	if err != nil {
+		return nil, err
 	}
 
 	key, ok := service.StrategyConfig["key"].(string)
@@ -82,8 +92,53 @@ func NewCookieSource(service *utils.Service) (*cookie_source, error) {
 	}
 
 	return &cookie_source{
-		cookieName:      name,
+		cookie:          spec,
 		cookieKey:       key,
 		injectIfMissing: injectIfMissing,
 	}, nil
 }
+
+// cookieConfig reads the cookie attributes from strategy_config: name, path, domain, max_age_s, secure,
+// same_site and secrets, with the same meaning as in sticky_cookie.
+func cookieConfig(strategyConfig map[string]any) (utils.CookieConfig, error) {
+	var cfg utils.CookieConfig
+	for field, dst := range map[string]*string{
+		"name": &cfg.Name, "path": &cfg.Path, "domain": &cfg.Domain, "same_site": &cfg.SameSite,
+	} {
+		if raw, exists := strategyConfig[field]; exists {
+			val, ok := raw.(string)
+			if !ok {
+				return cfg, fmt.Errorf("%s must be a string", field)
+			}
+			*dst = val
+		}
+	}
+	if raw, exists := strategyConfig["max_age_s"]; exists {
+		val, ok := raw.(int)
+		if !ok {
+			return cfg, errors.New("max_age_s must be an integer")
+		}
+		cfg.MaxAgeS = int64(val)
+	}
+	if raw, exists := strategyConfig["secure"]; exists {
+		val, ok := raw.(bool)
+		if !ok {
+			return cfg, errors.New("secure value must be a true/false")
+		}
+		cfg.Secure = val
+	}
+	if raw, exists := strategyConfig["secrets"]; exists {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return cfg, errors.New("secrets must be a list of strings")
+		}
+		for _, item := range list {
+			secret, ok := item.(string)
+			if !ok {
+				return cfg, errors.New("secrets must be a list of strings")
+			}
+			cfg.Secrets = append(cfg.Secrets, secret)
+		}
+	}
+	return cfg, nil
+}
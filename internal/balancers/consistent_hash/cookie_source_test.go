@@ -2,6 +2,8 @@ package consistent_hash
 
 import (
 	"encoding/base64"
+	"github.com/aribhuiya/stormgate/internal/cookies"
+	"github.com/aribhuiya/stormgate/internal/utils"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -9,12 +11,12 @@ import (
 
 func TestCookieSource_getSource(t *testing.T) {
 	tests := []struct {
-		name            string
-		req             *http.Request
-		source          *cookie_source
-		expectInject    bool
-		expectNonEmpty  bool
-		expectedContext bool
+		name           string
+		req            *http.Request
+		source         *cookie_source
+		expectInject   bool
+		expectNonEmpty bool
+		expectedCookie bool
 	}{
 		{
 			name: "cookie present with value and key",
@@ -28,7 +30,7 @@ func TestCookieSource_getSource(t *testing.T) {
 				return r
 			}(),
 			source: &cookie_source{
-				cookieName:      "cookie_name",
+				cookie:          &cookies.Spec{Name: "cookie_name"},
 				cookieKey:       "X-User-ID",
 				injectIfMissing: false,
 			},
@@ -40,13 +42,13 @@ func TestCookieSource_getSource(t *testing.T) {
 				return httptest.NewRequest("GET", "/api/v2", nil)
 			}(),
 			source: &cookie_source{
-				cookieName:      "cookie_name",
+				cookie:          &cookies.Spec{Name: "cookie_name"},
 				cookieKey:       "X-User-ID",
 				injectIfMissing: true,
 			},
-			expectNonEmpty:  true,
-			expectInject:    true,
-			expectedContext: true,
+			expectNonEmpty: true,
+			expectInject:   true,
+			expectedCookie: true,
 		},
 		{
 			name: "cookie present but missing key, fallback inject false",
@@ -60,7 +62,7 @@ func TestCookieSource_getSource(t *testing.T) {
 				return r
 			}(),
 			source: &cookie_source{
-				cookieName:      "cookie_name",
+				cookie:          &cookies.Spec{Name: "cookie_name"},
 				cookieKey:       "X-User-ID",
 				injectIfMissing: false,
 			},
@@ -78,7 +80,7 @@ func TestCookieSource_getSource(t *testing.T) {
 				return r
 			}(),
 			source: &cookie_source{
-				cookieName:      "cookie_name",
+				cookie:          &cookies.Spec{Name: "cookie_name"},
 				cookieKey:       "",
 				injectIfMissing: false,
 			},
@@ -95,7 +97,7 @@ func TestCookieSource_getSource(t *testing.T) {
 				return r
 			}(),
 			source: &cookie_source{
-				cookieName:      "cookie_name",
+				cookie:          &cookies.Spec{Name: "cookie_name"},
 				cookieKey:       "X-User-ID",
 				injectIfMissing: false,
 			},
@@ -113,7 +115,7 @@ func TestCookieSource_getSource(t *testing.T) {
 				return r
 			}(),
 			source: &cookie_source{
-				cookieName:      "cookie_name",
+				cookie:          &cookies.Spec{Name: "cookie_name"},
 				cookieKey:       "X-User-ID",
 				injectIfMissing: false,
 			},
@@ -131,7 +133,7 @@ func TestCookieSource_getSource(t *testing.T) {
 				return r
 			}(),
 			source: &cookie_source{
-				cookieName:      "cookie_name",
+				cookie:          &cookies.Spec{Name: "cookie_name"},
 				cookieKey:       "X-User-ID",
 				injectIfMissing: false,
 			},
@@ -149,7 +151,7 @@ func TestCookieSource_getSource(t *testing.T) {
 				return r
 			}(),
 			source: &cookie_source{
-				cookieName:      "cookie_name",
+				cookie:          &cookies.Spec{Name: "cookie_name"},
 				cookieKey:       "X-User-ID",
 				injectIfMissing: false,
 			},
@@ -159,7 +161,8 @@ func TestCookieSource_getSource(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			val := tt.source.getSource(tt.req)
+			ctx, jar := cookies.NewContext(tt.req.Context())
+			val := tt.source.getSource(tt.req.WithContext(ctx))
 
 			if tt.expectNonEmpty && val == "" {
 				t.Errorf("expected non-empty string, got empty")
@@ -168,12 +171,60 @@ func TestCookieSource_getSource(t *testing.T) {
 				t.Errorf("expected empty string, got '%s'", val)
 			}
 
-			if tt.expectedContext {
-				cVal := tt.req.Context().Value("inject_cookie")
-				if cVal == nil {
-					t.Errorf("expected context value 'inject_cookie' to be set")
+			if tt.expectedCookie {
+				set := jar.Cookies()
+				if len(set) != 1 || set[0].Name != "cookie_name" || set[0].Value != val {
+					t.Errorf("expected cookie_name=%s to be queued for the response, got %v", val, set)
 				}
 			}
 		})
 	}
 }
+
+func TestNewCookieSource_Attributes(t *testing.T) {
+	source, err := NewCookieSource(&utils.Service{PathPrefix: "/api/", StrategyConfig: map[string]any{
+		"inject_if_missing": true,
+		"name":              "sid",
+		"max_age_s":         3600,
+		"secure":            true,
+		"same_site":         "lax",
+		"secrets":           []interface{}{"k1"},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/", nil)
+	ctx, jar := cookies.NewContext(req.Context())
+	key := source.getSource(req.WithContext(ctx))
+	set := jar.Cookies()
+	if len(set) != 1 {
+		t.Fatalf("expected an injected cookie, got %v", set)
+	}
+	c := set[0]
+	if c.Name != "sid" || c.Path != "/api/" || c.MaxAge != 3600 || !c.Secure || c.SameSite != http.SameSiteLaxMode {
+		t.Errorf("injected cookie = %+v, want configured attributes", c)
+	}
+
+	// The signed cookie maps back to the same key; a forged one is replaced.
+	req = httptest.NewRequest("GET", "/api/", nil)
+	req.AddCookie(&http.Cookie{Name: "sid", Value: c.Value})
+	if got := source.getSource(req); got != key {
+		t.Errorf("getSource() = %q, want %q", got, key)
+	}
+	req = httptest.NewRequest("GET", "/api/", nil)
+	req.AddCookie(&http.Cookie{Name: "sid", Value: key})
+	if got := source.getSource(req); got == key {
+		t.Errorf("unsigned cookie was accepted")
+	}
+
+	for _, cfg := range []map[string]any{
+		{"inject_if_missing": true, "same_site": "always"},
+		{"inject_if_missing": true, "secure": "yes"},
+		{"inject_if_missing": true, "secrets": "k1"},
+	} {
+		if _, err := NewCookieSource(&utils.Service{StrategyConfig: cfg}); err == nil {
+			t.Errorf("NewCookieSource(%v) expected error", cfg)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package consistent_hash
+
+import (
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHashSources_getSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]any
+		target string
+		host   string
+		want   string
+	}{
+		{name: "query", config: map[string]any{"source": "query", "key": "user"}, target: "/api?user=42&x=1", want: "42"},
+		{name: "query missing", config: map[string]any{"source": "query", "key": "user"}, target: "/api?x=1", want: ""},
+		{name: "path index", config: map[string]any{"source": "path_segment", "index": 1}, target: "/users/42/orders", want: "42"},
+		{name: "path negative index", config: map[string]any{"source": "path_segment", "index": -1}, target: "/users/42/orders/", want: "orders"},
+		{name: "path index out of range", config: map[string]any{"source": "path_segment", "index": 5}, target: "/users/42", want: ""},
+		{
+			name:   "path named capture",
+			config: map[string]any{"source": "path_segment", "pattern": `^/tenants/(?P<tenant>[^/]+)/`, "capture": "tenant"},
+			target: "/tenants/acme/items/7",
+			want:   "acme",
+		},
+		{
+			name:   "path capture no match",
+			config: map[string]any{"source": "path_segment", "pattern": `^/tenants/(?P<tenant>[^/]+)/`, "capture": "tenant"},
+			target: "/users/1",
+			want:   "",
+		},
+		{name: "host", config: map[string]any{"source": "host"}, target: "/", host: "API.Example.com:8080", want: "api.example.com"},
+		{
+			name: "composite",
+			config: map[string]any{"source": "composite", "separator": ":", "sources": []interface{}{
+				map[string]interface{}{"source": "host"},
+				map[string]interface{}{"source": "path_segment", "index": 0},
+			}},
+			target: "/shop/cart",
+			host:   "example.com",
+			want:   "example.com:shop",
+		},
+		{
+			name: "composite with missing part",
+			config: map[string]any{"source": "composite", "sources": []interface{}{
+				map[string]interface{}{"source": "host"},
+				map[string]interface{}{"source": "query", "key": "user"},
+			}},
+			target: "/shop",
+			host:   "example.com",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := newHashSource(&utils.Service{StrategyConfig: tt.config})
+			if err != nil {
+				t.Fatalf("newHashSource() unexpected error: %v", err)
+			}
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			if got := source.getSource(req); got != tt.want {
+				t.Errorf("getSource() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHashSource_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]any
+	}{
+		{name: "query without key", config: map[string]any{"source": "query"}},
+		{name: "path without index or pattern", config: map[string]any{"source": "path_segment"}},
+		{name: "path with both", config: map[string]any{"source": "path_segment", "index": 0, "pattern": "(?P<a>.*)", "capture": "a"}},
+		{name: "path unknown capture", config: map[string]any{"source": "path_segment", "pattern": "(?P<a>.*)", "capture": "b"}},
+		{name: "path invalid pattern", config: map[string]any{"source": "path_segment", "pattern": "(", "capture": "a"}},
+		{name: "composite without sources", config: map[string]any{"source": "composite"}},
+		{name: "composite with bad entry", config: map[string]any{"source": "composite", "sources": []interface{}{"host"}}},
+		{name: "composite with unknown source", config: map[string]any{"source": "composite", "sources": []interface{}{
+			map[string]interface{}{"source": "moon"},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newHashSource(&utils.Service{StrategyConfig: tt.config}); err == nil {
+				t.Errorf("newHashSource() expected error")
+			}
+		})
+	}
+}
+
+func TestDeriveKey_FallbackChain(t *testing.T) {
+	source, fallbacks, err := newHashSources(&utils.Service{StrategyConfig: map[string]any{
+		"source": "header",
+		"key":    "X-User-ID",
+		"fallback": []interface{}{
+			map[string]interface{}{"source": "query", "key": "user"},
+			map[string]interface{}{"source": "cookie", "name": "session"},
+		},
+		"fallback_to_ip": true,
+	}})
+	if err != nil {
+		t.Fatalf("newHashSources() unexpected error: %v", err)
+	}
+	if len(fallbacks) != 3 {
+		t.Fatalf("len(fallbacks) = %d, want query, cookie and ip", len(fallbacks))
+	}
+
+	req := httptest.NewRequest("GET", "/?user=q1", nil)
+	req.Header.Set("X-User-ID", "h1")
+	if key, _ := deriveKey(req, source, fallbacks); key != "h1" {
+		t.Errorf("deriveKey() = %q, want the primary source", key)
+	}
+
+	req.Header.Del("X-User-ID")
+	if key, _ := deriveKey(req, source, fallbacks); key != "q1" {
+		t.Errorf("deriveKey() = %q, want the first fallback", key)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.4:1234"
+	if key, _ := deriveKey(req, source, fallbacks); key != "198.51.100.4" {
+		t.Errorf("deriveKey() = %q, want the ip fallback", key)
+	}
+
+	if _, _, err := newHashSources(&utils.Service{StrategyConfig: map[string]any{"source": "ip", "fallback": "ip"}}); err == nil {
+		t.Errorf("expected error for a fallback that isn't a list")
+	}
+}
@@ -0,0 +1,24 @@
+package consistent_hash
+
+import (
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hostSource keys on the requested host name, without the port and case-insensitively.
+type hostSource struct {
+}
+
+func NewHostSource(_ *utils.Service) *hostSource {
+	return &hostSource{}
+}
+
+func (h *hostSource) getSource(req *http.Request) string {
+	host := req.Host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	return strings.ToLower(host)
+}
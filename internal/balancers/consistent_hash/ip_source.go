@@ -1,11 +1,13 @@
 package consistent_hash
 
 import (
+	"github.com/aribhuiya/stormgate/internal/clientip"
 	"github.com/aribhuiya/stormgate/internal/utils"
-	"net"
 	"net/http"
 )
 
+// ipSource keys on the client address resolved by the gateway, which only honours forwarding headers set by
+// trusted proxies.
 type ipSource struct {
 }
 
@@ -14,15 +16,5 @@ func NewIPSource(_ *utils.Service) *ipSource {
 }
 
 func (s *ipSource) getSource(req *http.Request) string {
-	// Check for common headers first (e.g. when behind a reverse proxy)
-	ip := req.Header.Get("X-Forwarded-For")
-	if ip == "" {
-		ip = req.Header.Get("X-Real-IP")
-	}
-
-	if ip == "" {
-		ip, _, _ = net.SplitHostPort(req.RemoteAddr)
-	}
-
-	return ip
+	return clientip.FromRequest(req)
 }
@@ -0,0 +1,146 @@
+package consistent_hash
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"github.com/cespare/xxhash/v2"
+	"net/http"
+	"sync/atomic"
+)
+
+// DefaultMaglevTableSize is the lookup table size used when table_size is not configured.
+// It must be prime and should be much larger than the number of backends (the paper suggests >100x).
+const DefaultMaglevTableSize = 65537
+
+const maglevSkipSeed = 0x9e3779b97f4a7c15
+
+// Maglev implements Google's Maglev hashing: each backend fills a prime-sized lookup table following its
+// own permutation, giving O(1) picks, near-even spread and minimal remapping when the backend set changes.
+type Maglev struct {
+	service   *utils.Service
+	source    hashSource
+	fallbacks []hashSource
+	tableSize uint64
+	table     atomic.Pointer[maglevTable] // swapped whole by SetHealthyBackends
+}
+
+type maglevTable struct {
+	backends []string
+	entries  []int32 // index into backends for every table slot
+}
+
+func NewMaglev(service *utils.Service) (*Maglev, error) {
+	backends := service.BackendURLs()
+	if len(backends) == 0 {
+		err := errors.New("no available backends")
+		return nil, err
+	}
+	hashKeySource, fallbacks, err := newHashSources(service)
+	if err != nil {
+		return nil, err
+	}
+
+	tableSize := uint64(DefaultMaglevTableSize)
+	if raw, exists := service.StrategyConfig["table_size"]; exists {
+		val, ok := raw.(int)
+		if !ok || val <= 0 {
+			return nil, errors.New("table_size must be a positive integer")
+		}
+		tableSize = uint64(val)
+	}
+	if !isPrime(tableSize) {
+		return nil, fmt.Errorf("table_size %d must be a prime number", tableSize)
+	}
+	if tableSize < uint64(len(backends)) {
+		return nil, fmt.Errorf("table_size %d is smaller than the number of backends (%d)", tableSize, len(backends))
+	}
+
+	m := &Maglev{
+		service:   service,
+		source:    hashKeySource,
+		fallbacks: fallbacks,
+		tableSize: tableSize,
+	}
+	m.table.Store(buildMaglevTable(backends, tableSize))
+	return m, nil
+}
+
+func (m *Maglev) PickBackend(req *http.Request) (string, error) {
+	table := m.table.Load()
+	if len(table.backends) == 0 {
+		return "", utils.ErrNoHealthyBackends
+	}
+	key, err := deriveKey(req, m.source, m.fallbacks)
+	if err != nil {
+		return "", err
+	}
+	slot := hashString(key) % m.tableSize
+	return table.backends[table.entries[slot]], nil
+}
+
+func (m *Maglev) SetHealthyBackends(healthyBackends []string) {
+	if hasChanged := utils.HasBackendChanged(m.table.Load().backends, healthyBackends); !hasChanged {
+		return
+	}
+	m.table.Store(buildMaglevTable(healthyBackends, m.tableSize))
+}
+
+// buildMaglevTable runs the population algorithm from the Maglev paper (section 3.4): backends take turns
+// claiming the next free slot of their permutation until the table is full.
+func buildMaglevTable(backends []string, size uint64) *maglevTable {
+	backends = append([]string(nil), backends...)
+	table := &maglevTable{backends: backends}
+	n := len(backends)
+	if n == 0 {
+		return table
+	}
+
+	offsets := make([]uint64, n)
+	skips := make([]uint64, n)
+	for i, b := range backends {
+		offsets[i] = hashString(b) % size
+		skips[i] = hashWithSeed(b, maglevSkipSeed)%(size-1) + 1
+	}
+
+	entries := make([]int32, size)
+	for i := range entries {
+		entries[i] = -1
+	}
+	next := make([]uint64, n)
+	filled := uint64(0)
+	for {
+		for i := 0; i < n; i++ {
+			slot := (offsets[i] + next[i]*skips[i]) % size
+			for entries[slot] >= 0 {
+				next[i]++
+				slot = (offsets[i] + next[i]*skips[i]) % size
+			}
+			entries[slot] = int32(i)
+			next[i]++
+			filled++
+			if filled == size {
+				table.entries = entries
+				return table
+			}
+		}
+	}
+}
+
+func hashWithSeed(s string, seed uint64) uint64 {
+	d := xxhash.NewWithSeed(seed)
+	_, _ = d.WriteString(s)
+	return d.Sum64()
+}
+
+func isPrime(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for i := uint64(2); i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
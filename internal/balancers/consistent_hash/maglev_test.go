@@ -0,0 +1,167 @@
+package consistent_hash
+
+import (
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+	"testing"
+)
+
+func TestNewMaglev_Config(t *testing.T) {
+	tests := []struct {
+		name        string
+		backends    []string
+		config      map[string]any
+		expectError bool
+	}{
+		{
+			name:     "default table size",
+			backends: []string{"A", "B"},
+			config:   map[string]any{"source": "ip"},
+		},
+		{
+			name:     "custom prime table size",
+			backends: []string{"A", "B", "C"},
+			config:   map[string]any{"source": "ip", "table_size": 251},
+		},
+		{
+			name:        "table size not prime",
+			backends:    []string{"A", "B"},
+			config:      map[string]any{"source": "ip", "table_size": 100},
+			expectError: true,
+		},
+		{
+			name:        "table size smaller than backends",
+			backends:    []string{"A", "B", "C", "D"},
+			config:      map[string]any{"source": "ip", "table_size": 3},
+			expectError: true,
+		},
+		{
+			name:        "missing source",
+			backends:    []string{"A", "B"},
+			config:      map[string]any{},
+			expectError: true,
+		},
+		{
+			name:        "no backends",
+			backends:    []string{},
+			config:      map[string]any{"source": "ip"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMaglev(&utils.Service{Backends: utils.BackendsFromURLs(tt.backends...), StrategyConfig: tt.config})
+			if (err != nil) != tt.expectError {
+				t.Errorf("NewMaglev() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestMaglev_TableBalance(t *testing.T) {
+	backends := []string{"http://a:80", "http://b:80", "http://c:80", "http://d:80", "http://e:80"}
+	table := buildMaglevTable(backends, 65537)
+
+	counts := make([]int, len(backends))
+	for _, e := range table.entries {
+		if e < 0 {
+			t.Fatalf("table has an unfilled slot")
+		}
+		counts[e]++
+	}
+	ideal := 65537 / len(backends)
+	for i, c := range counts {
+		if c < ideal-1 || c > ideal+1 {
+			t.Errorf("backend %s owns %d slots, want %d±1", backends[i], c, ideal)
+		}
+	}
+}
+
+func TestMaglev_PickBackend_Deterministic(t *testing.T) {
+	m, err := NewMaglev(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B", "C"),
+		StrategyConfig: map[string]any{"source": "header", "key": "X-User-ID"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-User-ID", "user42")
+
+	first, err := m.PickBackend(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		got, _ := m.PickBackend(req)
+		if got != first {
+			t.Errorf("call %d: got %s, want %s", i, got, first)
+		}
+	}
+
+	missing, _ := http.NewRequest("GET", "/", nil)
+	if _, err := m.PickBackend(missing); err == nil {
+		t.Errorf("expected error when the hash key is missing")
+	}
+}
+
+func TestMaglev_MinimalDisruption(t *testing.T) {
+	backends := []string{"A", "B", "C", "D", "E"}
+	m, err := NewMaglev(&utils.Service{
+		Backends:       utils.BackendsFromURLs(backends...),
+		StrategyConfig: map[string]any{"source": "header", "key": "X-User-ID", "table_size": 5003},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const keys = 5000
+	before := make([]string, keys)
+	for i := 0; i < keys; i++ {
+		before[i] = pickWithKey(t, m, i)
+	}
+
+	m.SetHealthyBackends([]string{"A", "B", "D", "E"})
+
+	moved := 0
+	for i := 0; i < keys; i++ {
+		after := pickWithKey(t, m, i)
+		if after == "C" {
+			t.Fatalf("key %d routed to removed backend", i)
+		}
+		if before[i] != "C" && after != before[i] {
+			moved++
+		}
+	}
+	// Keys that were not on the removed backend should almost never move.
+	if moved > keys/50 {
+		t.Errorf("%d of %d keys on surviving backends were remapped", moved, keys)
+	}
+}
+
+func TestMaglev_NoHealthyBackends(t *testing.T) {
+	m, err := NewMaglev(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B"),
+		StrategyConfig: map[string]any{"source": "ip"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m.SetHealthyBackends(nil)
+	if _, err := m.PickBackend(&http.Request{RemoteAddr: "10.0.0.1:1234"}); err == nil {
+		t.Errorf("expected error with no healthy backends")
+	}
+}
+
+func pickWithKey(t *testing.T, m *Maglev, i int) string {
+	t.Helper()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-User-ID", fmt.Sprintf("user-%d", i))
+	got, err := m.PickBackend(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return got
+}
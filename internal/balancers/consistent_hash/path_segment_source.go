@@ -0,0 +1,77 @@
+package consistent_hash
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// pathSegmentSource keys on part of the request path: either the segment at index (0 is the first segment,
+// negative values count from the end) or the named capture group of pattern.
+type pathSegmentSource struct {
+	index   int
+	pattern *regexp.Regexp
+	group   int
+}
+
+func NewPathSegmentSource(service *utils.Service) (*pathSegmentSource, error) {
+	rawIndex, hasIndex := service.StrategyConfig["index"]
+	rawPattern, hasPattern := service.StrategyConfig["pattern"]
+	if hasIndex == hasPattern {
+		return nil, errors.New("source path_segment needs exactly one of index or pattern")
+	}
+
+	if hasIndex {
+		index, ok := rawIndex.(int)
+		if !ok {
+			return nil, errors.New("index must be an integer")
+		}
+		return &pathSegmentSource{index: index}, nil
+	}
+
+	expr, ok := rawPattern.(string)
+	if !ok {
+		return nil, errors.New("pattern must be a string")
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	capture, ok := service.StrategyConfig["capture"].(string)
+	if !ok {
+		return nil, errors.New("capture is required with pattern")
+	}
+	group := pattern.SubexpIndex(capture)
+	if group < 0 {
+		return nil, fmt.Errorf("pattern has no capture group named %q", capture)
+	}
+	return &pathSegmentSource{pattern: pattern, group: group}, nil
+}
+
+func (p *pathSegmentSource) getSource(req *http.Request) string {
+	if req.URL == nil {
+		return ""
+	}
+	path := req.URL.Path
+
+	if p.pattern != nil {
+		match := p.pattern.FindStringSubmatch(path)
+		if match == nil {
+			return ""
+		}
+		return match[p.group]
+	}
+
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	index := p.index
+	if index < 0 {
+		index += len(segments)
+	}
+	if index < 0 || index >= len(segments) {
+		return ""
+	}
+	return segments[index]
+}
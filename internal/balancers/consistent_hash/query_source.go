@@ -0,0 +1,26 @@
+package consistent_hash
+
+import (
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+)
+
+type querySource struct {
+	param string
+}
+
+func NewQuerySource(service *utils.Service) (*querySource, error) {
+	key, ok := service.StrategyConfig["key"].(string)
+	if !ok || key == "" {
+		return nil, errors.New("key is required for source query")
+	}
+	return &querySource{param: key}, nil
+}
+
+func (q *querySource) getSource(req *http.Request) string {
+	if req.URL == nil {
+		return ""
+	}
+	return req.URL.Query().Get(q.param)
+}
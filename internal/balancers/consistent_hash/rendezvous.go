@@ -0,0 +1,136 @@
+package consistent_hash
+
+import (
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/balancers/slowstart"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"math"
+	"net/http"
+	"sync/atomic"
+)
+
+// Rendezvous implements weighted highest-random-weight hashing: every backend is scored against the request
+// key and the highest score wins. Adding or removing a backend only remaps the keys that backend wins or loses.
+type Rendezvous struct {
+	service   *utils.Service
+	source    hashSource
+	fallbacks []hashSource
+	weightOf  map[string]float64 // configured weight per backend, fixed at construction
+	slowStart *slowstart.Tracker
+	nodes     atomic.Pointer[[]rendezvousNode]
+}
+
+type rendezvousNode struct {
+	backend string
+	hash    uint64 // hash of the backend name, mixed with the key hash when scoring
+	weight  float64
+}
+
+func NewRendezvous(service *utils.Service) (*Rendezvous, error) {
+	backends := service.BackendURLs()
+	if len(backends) == 0 {
+		err := errors.New("no available backends")
+		return nil, err
+	}
+	hashKeySource, fallbacks, err := newHashSources(service)
+	if err != nil {
+		return nil, err
+	}
+	weights, err := utils.ParseWeights(service)
+	if err != nil {
+		return nil, err
+	}
+	slowStart, err := slowstart.NewTracker(service)
+	if err != nil {
+		return nil, err
+	}
+
+	weightOf := make(map[string]float64, len(backends))
+	for i, backend := range backends {
+		weightOf[backend] = 1
+		if weights != nil {
+			weightOf[backend] = float64(weights[i])
+		}
+	}
+
+	r := &Rendezvous{
+		service:   service,
+		source:    hashKeySource,
+		fallbacks: fallbacks,
+		weightOf:  weightOf,
+		slowStart: slowStart,
+	}
+	r.nodes.Store(r.buildNodes(backends))
+	return r, nil
+}
+
+func (r *Rendezvous) buildNodes(backends []string) *[]rendezvousNode {
+	nodes := make([]rendezvousNode, 0, len(backends))
+	for _, backend := range backends {
+		weight, ok := r.weightOf[backend]
+		if !ok {
+			weight = 1
+		}
+		nodes = append(nodes, rendezvousNode{
+			backend: backend,
+			hash:    hashString(backend),
+			weight:  weight,
+		})
+	}
+	return &nodes
+}
+
+func (r *Rendezvous) PickBackend(req *http.Request) (string, error) {
+	nodes := *r.nodes.Load()
+	if len(nodes) == 0 {
+		return "", utils.ErrNoHealthyBackends
+	}
+	key, err := deriveKey(req, r.source, r.fallbacks)
+	if err != nil {
+		return "", err
+	}
+
+	keyHash := hashString(key)
+	ramping := r.slowStart.Ramping()
+	best := 0
+	bestScore := math.Inf(-1)
+	for i, node := range nodes {
+		if ramping {
+			node.weight *= r.slowStart.Factor(node.backend)
+		}
+		if score := rendezvousScore(keyHash, node); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return nodes[best].backend, nil
+}
+
+func (r *Rendezvous) SetHealthyBackends(healthyBackends []string) {
+	nodes := *r.nodes.Load()
+	current := make([]string, len(nodes))
+	for i, node := range nodes {
+		current[i] = node.backend
+	}
+	if hasChanged := utils.HasBackendChanged(current, healthyBackends); !hasChanged {
+		return
+	}
+	r.slowStart.Update(healthyBackends)
+	r.nodes.Store(r.buildNodes(healthyBackends))
+}
+
+// rendezvousScore uses the logarithmic method (Schindelhauer & Schomaker) so that each backend wins a share of
+// keys proportional to its weight: score = -w / ln(u) with u uniform in (0, 1).
+func rendezvousScore(keyHash uint64, node rendezvousNode) float64 {
+	u := (float64(mix64(keyHash^node.hash)>>11) + 0.5) / (1 << 53)
+	return -node.weight / math.Log(u)
+}
+
+// mix64 is the splitmix64 finalizer; it turns the xor of two hashes into a well distributed value.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
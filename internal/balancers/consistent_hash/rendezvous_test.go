@@ -0,0 +1,131 @@
+package consistent_hash
+
+import (
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+	"testing"
+)
+
+func TestNewRendezvous_Config(t *testing.T) {
+	tests := []struct {
+		name        string
+		backends    []string
+		config      map[string]any
+		expectError bool
+	}{
+		{
+			name:     "unweighted",
+			backends: []string{"A", "B"},
+			config:   map[string]any{"source": "ip"},
+		},
+		{
+			name:     "weighted",
+			backends: []string{"A", "B"},
+			config:   map[string]any{"source": "ip", "weights": []interface{}{3, 1}},
+		},
+		{
+			name:        "weights length mismatch",
+			backends:    []string{"A", "B"},
+			config:      map[string]any{"source": "ip", "weights": []interface{}{3}},
+			expectError: true,
+		},
+		{
+			name:        "zero weight",
+			backends:    []string{"A", "B"},
+			config:      map[string]any{"source": "ip", "weights": []interface{}{1, 0}},
+			expectError: true,
+		},
+		{
+			name:        "unknown source",
+			backends:    []string{"A", "B"},
+			config:      map[string]any{"source": "query"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRendezvous(&utils.Service{Backends: utils.BackendsFromURLs(tt.backends...), StrategyConfig: tt.config})
+			if (err != nil) != tt.expectError {
+				t.Errorf("NewRendezvous() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestRendezvous_WeightedDistribution(t *testing.T) {
+	r, err := NewRendezvous(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B"),
+		StrategyConfig: map[string]any{"source": "header", "key": "X-User-ID", "weights": []interface{}{3, 1}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const keys = 20000
+	counts := map[string]int{}
+	for i := 0; i < keys; i++ {
+		counts[pickRendezvous(t, r, i)]++
+	}
+	share := float64(counts["A"]) / keys
+	if share < 0.72 || share > 0.78 {
+		t.Errorf("backend A received %.3f of keys, want ~0.75 (counts %v)", share, counts)
+	}
+}
+
+func TestRendezvous_MinimalRemapping(t *testing.T) {
+	r, err := NewRendezvous(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B", "C", "D"),
+		StrategyConfig: map[string]any{"source": "header", "key": "X-User-ID"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const keys = 5000
+	before := make([]string, keys)
+	for i := 0; i < keys; i++ {
+		before[i] = pickRendezvous(t, r, i)
+	}
+
+	r.SetHealthyBackends([]string{"A", "B", "D"})
+	for i := 0; i < keys; i++ {
+		after := pickRendezvous(t, r, i)
+		if before[i] != "C" && after != before[i] {
+			t.Fatalf("key %d moved from %s to %s although its backend stayed healthy", i, before[i], after)
+		}
+	}
+
+	r.SetHealthyBackends([]string{"A", "B", "C", "D"})
+	for i := 0; i < keys; i++ {
+		if got := pickRendezvous(t, r, i); got != before[i] {
+			t.Fatalf("key %d did not return to %s after recovery, got %s", i, before[i], got)
+		}
+	}
+}
+
+func TestRendezvous_NoHealthyBackends(t *testing.T) {
+	r, err := NewRendezvous(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A"),
+		StrategyConfig: map[string]any{"source": "ip"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.SetHealthyBackends([]string{})
+	if _, err := r.PickBackend(&http.Request{RemoteAddr: "10.0.0.1:1234"}); err == nil {
+		t.Errorf("expected error with no healthy backends")
+	}
+}
+
+func pickRendezvous(t *testing.T, r *Rendezvous, i int) string {
+	t.Helper()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-User-ID", fmt.Sprintf("user-%d", i))
+	got, err := r.PickBackend(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return got
+}
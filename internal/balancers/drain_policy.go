@@ -0,0 +1,37 @@
+package balancers
+
+import (
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"log"
+)
+
+// drainPolicy takes backends marked drain out of rotation. They stop receiving new requests but are still
+// health checked, so in-flight requests finish normally and the backend can be removed once it is idle.
+type drainPolicy struct {
+	draining map[string]bool
+	names    []string
+}
+
+// NewDrainPolicy returns nil when no backend of the service is draining.
+func NewDrainPolicy(service *utils.Service) Policy {
+	d := &drainPolicy{draining: map[string]bool{}}
+	for _, b := range service.AllBackends() {
+		if b.Drain {
+			d.draining[b.URL] = true
+			d.names = append(d.names, b.URL)
+		}
+	}
+	if len(d.names) == 0 {
+		return nil
+	}
+	log.Printf("Service %s: draining %v", service.Name, d.names)
+	return d
+}
+
+func (d *drainPolicy) Apply(eligible, healthy []string) ([]string, []string) {
+	return exclude(eligible, d.draining), exclude(healthy, d.draining)
+}
+
+func (d *drainPolicy) Status(status map[string]any) {
+	status["draining"] = d.names
+}
@@ -0,0 +1,95 @@
+package balancers
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Policy narrows the backends a strategy may use, e.g. preferring primaries over backups or the local zone
+// over remote ones. Apply receives the backends still eligible at this stage and the healthy subset of them,
+// and returns the narrowed pair for the next stage. It is called from health-check goroutines.
+type Policy interface {
+	Apply(eligible, healthy []string) (narrowedEligible, narrowedHealthy []string)
+	// Status adds the policy's current decision to the admin status of the service.
+	Status(status map[string]any)
+}
+
+// Filtered runs every health update through its policies, in order, before handing the resulting healthy
+// set to the wrapped strategy. It works with any strategy, which must be built over all of the backends.
+type Filtered struct {
+	Balancer
+	all      []string
+	policies []Policy
+	mu       sync.Mutex
+	reported []string                 // last healthy set reported by the health checks, guarded by mu
+	active   atomic.Pointer[[]string] // last healthy set handed to the strategy
+}
+
+func NewFiltered(inner Balancer, all []string, policies ...Policy) *Filtered {
+	f := &Filtered{
+		Balancer: inner,
+		all:      all,
+		policies: policies,
+	}
+	// Until the first health check reports in, treat every backend as healthy.
+	f.SetHealthyBackends(all)
+	return f
+}
+
+func (f *Filtered) SetHealthyBackends(healthyBackends []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reported = healthyBackends
+	f.apply()
+}
+
+// Refresh re-runs the policies over the last reported healthy set, for policies whose decision changes
+// between health checks.
+func (f *Filtered) Refresh() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.apply()
+}
+
+func (f *Filtered) apply() {
+	eligible, healthy := f.all, f.reported
+	for _, policy := range f.policies {
+		eligible, healthy = policy.Apply(eligible, healthy)
+	}
+	if healthy == nil {
+		healthy = []string{}
+	}
+	f.active.Store(&healthy)
+	f.Balancer.SetHealthyBackends(healthy)
+}
+
+func (f *Filtered) Status() map[string]any {
+	status := innerStatus(f.Balancer)
+	status["active_backends"] = *f.active.Load()
+	for _, policy := range f.policies {
+		policy.Status(status)
+	}
+	return status
+}
+
+// intersect returns the members of list that are in set, preserving the order of list.
+func intersect(list []string, set map[string]bool) []string {
+	out := make([]string, 0, len(list))
+	for _, b := range list {
+		if set[b] {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// exclude returns the members of list that are not in set, preserving the order of list.
+func exclude(list []string, set map[string]bool) []string {
+	out := make([]string, 0, len(list))
+	for _, b := range list {
+		if !set[b] {
+			out = append(out, b)
+		}
+	}
+	return out
+}
@@ -0,0 +1,333 @@
+package balancers
+
+import (
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"testing"
+)
+
+// newTestFiltered builds a round robin over every backend of service, filtered by the given policies.
+func newTestFiltered(t *testing.T, service *utils.Service, policies ...Policy) *Filtered {
+	t.Helper()
+	inner, err := NewRoundRobin(service)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return NewFiltered(inner, service.BackendURLs(), policies...)
+}
+
+func newTestTierPolicy(t *testing.T, service *utils.Service) Policy {
+	t.Helper()
+	policy, err := NewTierPolicy(service)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return policy
+}
+
+func newTestZonePolicy(t *testing.T, service *utils.Service, zone string) Policy {
+	t.Helper()
+	policy, err := NewZonePolicy(service, zone)
+	if err != nil || policy == nil {
+		t.Fatalf("NewZonePolicy() = %v, %v", policy, err)
+	}
+	return policy
+}
+
+func pickSet(t *testing.T, b Balancer, n int) map[string]bool {
+	t.Helper()
+	seen := map[string]bool{}
+	for i := 0; i < n; i++ {
+		got, err := b.PickBackend(nil)
+		if err != nil {
+			t.Fatalf("PickBackend() unexpected error: %v", err)
+		}
+		seen[got] = true
+	}
+	return seen
+}
+
+func TestTierPolicy_Failover(t *testing.T) {
+	service := &utils.Service{Name: "svc", Backends: utils.BackendsFromURLs("P1", "P2"), BackupBackends: utils.BackendsFromURLs("B1")}
+	f := newTestFiltered(t, service, newTestTierPolicy(t, service))
+
+	if seen := pickSet(t, f, 10); seen["B1"] {
+		t.Errorf("backup received traffic before any health check: %v", seen)
+	}
+
+	f.SetHealthyBackends([]string{"P2", "B1"})
+	if seen := pickSet(t, f, 10); !seen["P2"] || seen["B1"] {
+		t.Errorf("expected only P2 while one primary is healthy, got %v", seen)
+	}
+
+	f.SetHealthyBackends([]string{"B1"})
+	if seen := pickSet(t, f, 10); !seen["B1"] || len(seen) != 1 {
+		t.Errorf("expected failover to B1, got %v", seen)
+	}
+	if tier := f.Status()["tier"]; tier != TIER_BACKUP {
+		t.Errorf("Status() tier = %v, want %v", tier, TIER_BACKUP)
+	}
+
+	f.SetHealthyBackends([]string{"P1", "P2", "B1"})
+	if seen := pickSet(t, f, 10); seen["B1"] {
+		t.Errorf("backup still receiving traffic after primaries recovered: %v", seen)
+	}
+	if tier := f.Status()["tier"]; tier != TIER_PRIMARY {
+		t.Errorf("Status() tier = %v, want %v", tier, TIER_PRIMARY)
+	}
+}
+
+func TestTierPolicy_FailoverThreshold(t *testing.T) {
+	service := &utils.Service{
+		Backends:          utils.BackendsFromURLs("P1", "P2", "P3", "P4"),
+		BackupBackends:    utils.BackendsFromURLs("B1"),
+		FailoverThreshold: 0.5,
+	}
+	f := newTestFiltered(t, service, newTestTierPolicy(t, service))
+
+	f.SetHealthyBackends([]string{"P1", "P2", "B1"})
+	if seen := pickSet(t, f, 10); seen["B1"] {
+		t.Errorf("backup used with half the primaries healthy: %v", seen)
+	}
+
+	// Below the threshold the remaining primaries and the backups share the traffic.
+	f.SetHealthyBackends([]string{"P1", "B1"})
+	if seen := pickSet(t, f, 10); !seen["P1"] || !seen["B1"] {
+		t.Errorf("expected P1 and B1 to share traffic, got %v", seen)
+	}
+}
+
+func TestNewTierPolicy_Validation(t *testing.T) {
+	if _, err := NewTierPolicy(&utils.Service{Backends: utils.BackendsFromURLs("A"), BackupBackends: utils.BackendsFromURLs("A")}); err == nil {
+		t.Errorf("expected error when a backend is both primary and backup")
+	}
+	if _, err := NewTierPolicy(&utils.Service{Backends: utils.BackendsFromURLs("A"), FailoverThreshold: 1.5}); err == nil {
+		t.Errorf("expected error for failover_threshold above 1")
+	}
+}
+
+func TestZonePolicy_PrefersLocalZone(t *testing.T) {
+	service := &utils.Service{
+		Backends:           utils.BackendsFromURLs("A1", "A2", "B1", "B2"),
+		BackendZones:       map[string]string{"A1": "zone-a", "A2": "zone-a", "B1": "zone-b", "B2": "zone-b"},
+		LocalZoneThreshold: 0.5,
+	}
+	f := newTestFiltered(t, service, newTestZonePolicy(t, service, "zone-a"))
+
+	if seen := pickSet(t, f, 10); seen["B1"] || seen["B2"] {
+		t.Errorf("remote zone received traffic while local is healthy: %v", seen)
+	}
+
+	f.SetHealthyBackends([]string{"A2", "B1", "B2"})
+	if seen := pickSet(t, f, 10); len(seen) != 1 || !seen["A2"] {
+		t.Errorf("expected to stay local at the threshold, got %v", seen)
+	}
+
+	f.SetHealthyBackends([]string{"B1", "B2"})
+	if seen := pickSet(t, f, 10); !seen["B1"] || !seen["B2"] {
+		t.Errorf("expected spill over to zone-b, got %v", seen)
+	}
+	if spill := f.Status()["zone_spillover"]; spill != true {
+		t.Errorf("Status() zone_spillover = %v, want true", spill)
+	}
+}
+
+func TestZonePolicy_SpillsBelowThreshold(t *testing.T) {
+	service := &utils.Service{
+		Backends:           utils.BackendsFromURLs("A1", "A2", "A3", "B1"),
+		BackendZones:       map[string]string{"A1": "zone-a", "A2": "zone-a", "A3": "zone-a", "B1": "zone-b"},
+		LocalZoneThreshold: 0.5,
+	}
+	f := newTestFiltered(t, service, newTestZonePolicy(t, service, "zone-a"))
+
+	f.SetHealthyBackends([]string{"A1", "B1"})
+	if seen := pickSet(t, f, 10); !seen["A1"] || !seen["B1"] {
+		t.Errorf("expected A1 and B1 to share traffic below the threshold, got %v", seen)
+	}
+}
+
+func TestZonePolicy_WithinTier(t *testing.T) {
+	service := &utils.Service{
+		Backends:       utils.BackendsFromURLs("PA", "PB"),
+		BackupBackends: utils.BackendsFromURLs("BA", "BB"),
+		BackendZones:   map[string]string{"PA": "zone-a", "PB": "zone-b", "BA": "zone-a", "BB": "zone-b"},
+	}
+	f := newTestFiltered(t, service, newTestTierPolicy(t, service), newTestZonePolicy(t, service, "zone-a"))
+
+	if seen := pickSet(t, f, 10); len(seen) != 1 || !seen["PA"] {
+		t.Errorf("expected local primary only, got %v", seen)
+	}
+
+	// Losing the local primary does not fail over to the local backup while a remote primary is healthy.
+	f.SetHealthyBackends([]string{"PB", "BA", "BB"})
+	if seen := pickSet(t, f, 10); len(seen) != 1 || !seen["PB"] {
+		t.Errorf("expected remote primary, got %v", seen)
+	}
+
+	f.SetHealthyBackends([]string{"BA", "BB"})
+	if seen := pickSet(t, f, 10); len(seen) != 1 || !seen["BA"] {
+		t.Errorf("expected local backup after all primaries failed, got %v", seen)
+	}
+}
+
+func TestNewZonePolicy_Validation(t *testing.T) {
+	service := &utils.Service{Backends: utils.BackendsFromURLs("A"), BackendZones: map[string]string{"A": "zone-a"}}
+	if policy, err := NewZonePolicy(service, ""); policy != nil || err != nil {
+		t.Errorf("expected zone-aware routing to be disabled without a local zone, got %v, %v", policy, err)
+	}
+	if policy, err := NewZonePolicy(service, "zone-b"); policy != nil || err != nil {
+		t.Errorf("expected zone-aware routing to be disabled with no local backends, got %v, %v", policy, err)
+	}
+	unknown := &utils.Service{Backends: utils.BackendsFromURLs("A"), BackendZones: map[string]string{"X": "zone-a"}}
+	if _, err := NewZonePolicy(unknown, "zone-a"); err == nil {
+		t.Errorf("expected error for a zone on an unknown backend")
+	}
+}
+
+func TestZonePolicy_BackendZones(t *testing.T) {
+	service := &utils.Service{Backends: []utils.Backend{
+		{URL: "A1", Zone: "zone-a"},
+		{URL: "B1", Zone: "zone-b"},
+	}}
+	f := newTestFiltered(t, service, newTestZonePolicy(t, service, "zone-a"))
+	if seen := pickSet(t, f, 10); len(seen) != 1 || !seen["A1"] {
+		t.Errorf("expected only the local backend, got %v", seen)
+	}
+}
+
+func TestDrainPolicy(t *testing.T) {
+	service := &utils.Service{Backends: []utils.Backend{{URL: "A"}, {URL: "B", Drain: true}, {URL: "C"}}}
+	drain := NewDrainPolicy(service)
+	if drain == nil {
+		t.Fatalf("NewDrainPolicy() = nil, want a policy")
+	}
+	f := newTestFiltered(t, service, drain)
+
+	if seen := pickSet(t, f, 10); seen["B"] {
+		t.Errorf("draining backend received traffic: %v", seen)
+	}
+	f.SetHealthyBackends([]string{"B"})
+	if _, err := f.PickBackend(nil); err == nil {
+		t.Errorf("expected error when only the draining backend is healthy")
+	}
+
+	if NewDrainPolicy(&utils.Service{Backends: utils.BackendsFromURLs("A")}) != nil {
+		t.Errorf("NewDrainPolicy() without draining backends should be nil")
+	}
+}
+
+func TestPanicPolicy(t *testing.T) {
+	service := &utils.Service{Name: "svc", Backends: []utils.Backend{{URL: "A"}, {URL: "B"}, {URL: "C"}, {URL: "D", Drain: true}}, PanicThreshold: 0.5}
+	panicMode, err := NewPanicPolicy(service)
+	if err != nil || panicMode == nil {
+		t.Fatalf("NewPanicPolicy() = %v, %v", panicMode, err)
+	}
+	f := newTestFiltered(t, service, NewDrainPolicy(service), panicMode)
+
+	f.SetHealthyBackends([]string{"A", "B"})
+	if seen := pickSet(t, f, 10); len(seen) != 2 || seen["C"] {
+		t.Errorf("expected only A and B above the threshold, got %v", seen)
+	}
+
+	// Every check failing: route to all backends except the draining one rather than rejecting requests.
+	f.SetHealthyBackends(nil)
+	if seen := pickSet(t, f, 10); len(seen) != 3 || seen["D"] {
+		t.Errorf("expected A, B and C in panic mode, got %v", seen)
+	}
+	status := f.Status()
+	if status["panic"] != true || status["panic_entered_total"] != int64(1) {
+		t.Errorf("status = %v, want panic mode reported once", status)
+	}
+
+	f.SetHealthyBackends([]string{"A", "C"})
+	if seen := pickSet(t, f, 10); len(seen) != 2 || seen["B"] {
+		t.Errorf("expected A and C after recovering, got %v", seen)
+	}
+	if f.Status()["panic"] != false {
+		t.Errorf("panic mode still reported after recovering")
+	}
+
+	for _, threshold := range []float64{-0.1, 1.5} {
+		if _, err := NewPanicPolicy(&utils.Service{PanicThreshold: threshold}); err == nil {
+			t.Errorf("NewPanicPolicy(%v) expected error", threshold)
+		}
+	}
+	if p, err := NewPanicPolicy(&utils.Service{}); p != nil || err != nil {
+		t.Errorf("NewPanicPolicy() without threshold = %v, %v, want nil", p, err)
+	}
+}
+
+func newTestPanicPolicy(t *testing.T, service *utils.Service) Policy {
+	t.Helper()
+	policy, err := NewPanicPolicy(service)
+	if err != nil || policy == nil {
+		t.Fatalf("NewPanicPolicy() = %v, %v", policy, err)
+	}
+	return policy
+}
+
+func TestPanicPolicy_AfterFailover(t *testing.T) {
+	service := &utils.Service{
+		Name:              "svc",
+		Backends:          utils.BackendsFromURLs("P1", "P2", "P3"),
+		BackupBackends:    utils.BackendsFromURLs("B1", "B2"),
+		FailoverThreshold: 0.5,
+		PanicThreshold:    0.6,
+	}
+	f := newTestFiltered(t, service, newTestTierPolicy(t, service), newTestPanicPolicy(t, service))
+
+	// Only 2 of 5 backends are healthy, but they are the whole backup tier: fail over, don't panic.
+	f.SetHealthyBackends([]string{"B1", "B2"})
+	if seen := pickSet(t, f, 10); len(seen) != 2 || !seen["B1"] || !seen["B2"] {
+		t.Errorf("expected failover to B1 and B2, got %v", seen)
+	}
+	if f.Status()["panic"] != false {
+		t.Errorf("panic mode entered although the backup tier is healthy")
+	}
+
+	// Below the threshold within the backup tier, panic mode spreads over that tier.
+	f.SetHealthyBackends([]string{"B1"})
+	if seen := pickSet(t, f, 10); len(seen) != 2 || !seen["B1"] || !seen["B2"] {
+		t.Errorf("expected panic over the backup tier, got %v", seen)
+	}
+
+	// A primary that is still healthy joins the backup tier, and panic mode covers it too, but the failed
+	// primaries stay out: panic never undoes the failover.
+	f.SetHealthyBackends([]string{"P1"})
+	if seen := pickSet(t, f, 10); len(seen) != 3 || !seen["P1"] || !seen["B1"] || !seen["B2"] {
+		t.Errorf("expected panic over P1, B1 and B2 only, got %v", seen)
+	}
+	if f.Status()["panic"] != true {
+		t.Errorf("panic mode not entered with 1 of 3 backup-tier backends healthy")
+	}
+}
+
+func TestPanicPolicy_AfterZoneSelection(t *testing.T) {
+	service := &utils.Service{
+		Name:           "svc",
+		Backends:       utils.BackendsFromURLs("A1", "A2", "B1", "B2"),
+		BackendZones:   map[string]string{"A1": "zone-a", "A2": "zone-a", "B1": "zone-b", "B2": "zone-b"},
+		PanicThreshold: 0.5,
+	}
+	f := newTestFiltered(t, service, newTestZonePolicy(t, service, "zone-a"), newTestPanicPolicy(t, service))
+
+	// Half of the pool is healthy, all of it remote: spill over instead of panicking back into the local zone.
+	f.SetHealthyBackends([]string{"B1", "B2"})
+	if seen := pickSet(t, f, 10); len(seen) != 2 || !seen["B1"] || !seen["B2"] {
+		t.Errorf("expected spill over to B1 and B2, got %v", seen)
+	}
+
+	// Panicking after a spill over covers every zone, not just the local one.
+	f.SetHealthyBackends([]string{"B1"})
+	if seen := pickSet(t, f, 10); len(seen) != 4 {
+		t.Errorf("expected panic across both zones, got %v", seen)
+	}
+
+	// The local zone alone decides while it isn't spilling.
+	f.SetHealthyBackends([]string{"A1", "A2"})
+	if seen := pickSet(t, f, 10); len(seen) != 2 || !seen["A1"] || !seen["A2"] {
+		t.Errorf("expected only the local zone, got %v", seen)
+	}
+	if f.Status()["panic"] != false {
+		t.Errorf("panic mode entered although the local zone is healthy")
+	}
+}
@@ -0,0 +1,45 @@
+package inflight
+
+import "sync/atomic"
+
+// Counter tracks the number of in-flight requests per backend. The set of backends is fixed at construction,
+// so lookups never lock; requests for backends it doesn't know about are ignored.
+type Counter struct {
+	counts map[string]*atomic.Int64
+}
+
+func NewCounter(backends []string) *Counter {
+	counts := make(map[string]*atomic.Int64, len(backends))
+	for _, b := range backends {
+		counts[b] = &atomic.Int64{}
+	}
+	return &Counter{counts: counts}
+}
+
+func (c *Counter) Inc(backend string) {
+	if v, ok := c.counts[backend]; ok {
+		v.Add(1)
+	}
+}
+
+func (c *Counter) Dec(backend string) {
+	if v, ok := c.counts[backend]; ok {
+		v.Add(-1)
+	}
+}
+
+func (c *Counter) Load(backend string) int64 {
+	if v, ok := c.counts[backend]; ok {
+		return v.Load()
+	}
+	return 0
+}
+
+// Total returns the sum of in-flight requests across the given backends.
+func (c *Counter) Total(backends []string) int64 {
+	total := int64(0)
+	for _, b := range backends {
+		total += c.Load(b)
+	}
+	return total
+}
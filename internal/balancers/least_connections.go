@@ -0,0 +1,120 @@
+package balancers
+
+import (
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/balancers/inflight"
+	"github.com/aribhuiya/stormgate/internal/balancers/slowstart"
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+)
+
+// LeastConnections picks the healthy backend with the fewest in-flight requests relative to its (slow-start
+// scaled) weight, breaking ties at random. In-flight counts are maintained by the proxy via RequestStarted/RequestFinished.
+type LeastConnections struct {
+	service   *utils.Service
+	inFlight  *inflight.Counter
+	weightOf  map[string]int64
+	slowStart *slowstart.Tracker
+	healthy   atomic.Pointer[[]weightedBackend]
+}
+
+type weightedBackend struct {
+	backend string
+	weight  int64
+}
+
+func NewLeastConnections(service *utils.Service) (*LeastConnections, error) {
+	backends := service.BackendURLs()
+	if len(backends) == 0 {
+		err := errors.New("no available backends")
+		return nil, err
+	}
+	weights, err := utils.ParseWeights(service)
+	if err != nil {
+		return nil, err
+	}
+	slowStart, err := slowstart.NewTracker(service)
+	if err != nil {
+		return nil, err
+	}
+
+	weightOf := make(map[string]int64, len(backends))
+	for i, backend := range backends {
+		weightOf[backend] = 1
+		if weights != nil {
+			weightOf[backend] = int64(weights[i])
+		}
+	}
+
+	l := &LeastConnections{
+		service:   service,
+		inFlight:  inflight.NewCounter(backends),
+		weightOf:  weightOf,
+		slowStart: slowStart,
+	}
+	l.healthy.Store(l.weighted(backends))
+	return l, nil
+}
+
+func (l *LeastConnections) weighted(backends []string) *[]weightedBackend {
+	out := make([]weightedBackend, 0, len(backends))
+	for _, backend := range backends {
+		weight, ok := l.weightOf[backend]
+		if !ok {
+			weight = 1
+		}
+		out = append(out, weightedBackend{backend: backend, weight: weight})
+	}
+	return &out
+}
+
+func (l *LeastConnections) PickBackend(*http.Request) (string, error) {
+	healthy := *l.healthy.Load()
+	if len(healthy) == 0 {
+		return "", utils.ErrNoHealthyBackends
+	}
+
+	best := -1
+	bestCost := 0.0
+	ties := 0
+	for i, candidate := range healthy {
+		// Counting the request being placed makes an idle heavy backend preferable to an idle light one.
+		weight := float64(candidate.weight) * l.slowStart.Factor(candidate.backend)
+		cost := float64(l.inFlight.Load(candidate.backend)+1) / weight
+		switch {
+		case best < 0 || cost < bestCost:
+			best, bestCost, ties = i, cost, 1
+		case cost == bestCost:
+			// Reservoir sampling keeps every tied backend equally likely.
+			ties++
+			if rand.Intn(ties) == 0 {
+				best = i
+			}
+		}
+	}
+	return healthy[best].backend, nil
+}
+
+func (l *LeastConnections) SetHealthyBackends(healthyBackends []string) {
+	current := *l.healthy.Load()
+	backends := make([]string, len(current))
+	for i, c := range current {
+		backends[i] = c.backend
+	}
+	if hasChanged := utils.HasBackendChanged(backends, healthyBackends); !hasChanged {
+		return
+	}
+	l.slowStart.Update(healthyBackends)
+	l.healthy.Store(l.weighted(healthyBackends))
+}
+
+func (l *LeastConnections) RequestStarted(backend string) {
+	l.inFlight.Inc(backend)
+}
+
+func (l *LeastConnections) RequestFinished(backend string, _ http_proxies.RequestResult) {
+	l.inFlight.Dec(backend)
+}
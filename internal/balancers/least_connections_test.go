@@ -0,0 +1,118 @@
+package balancers
+
+import (
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"testing"
+)
+
+func TestLeastConnections_PickBackend(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends []string
+		weights  []interface{}
+		inFlight map[string]int
+		want     []string // any of these is acceptable
+	}{
+		{
+			name:     "picks the least loaded backend",
+			backends: []string{"A", "B", "C"},
+			inFlight: map[string]int{"A": 3, "B": 1, "C": 2},
+			want:     []string{"B"},
+		},
+		{
+			name:     "ties are broken between the least loaded",
+			backends: []string{"A", "B", "C"},
+			inFlight: map[string]int{"A": 1, "B": 1, "C": 4},
+			want:     []string{"A", "B"},
+		},
+		{
+			name:     "weights scale the acceptable load",
+			backends: []string{"A", "B"},
+			weights:  []interface{}{4, 1},
+			inFlight: map[string]int{"A": 3, "B": 1},
+			want:     []string{"A"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &utils.Service{Backends: utils.BackendsFromURLs(tt.backends...), StrategyConfig: map[string]any{}}
+			if tt.weights != nil {
+				service.StrategyConfig["weights"] = tt.weights
+			}
+			l, err := NewLeastConnections(service)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for backend, n := range tt.inFlight {
+				for i := 0; i < n; i++ {
+					l.RequestStarted(backend)
+				}
+			}
+
+			got, err := l.PickBackend(nil)
+			if err != nil {
+				t.Fatalf("PickBackend() unexpected error: %v", err)
+			}
+			found := false
+			for _, w := range tt.want {
+				if got == w {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("PickBackend() = %v, want one of %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeastConnections_RandomTieBreaking(t *testing.T) {
+	l, err := NewLeastConnections(&utils.Service{Backends: utils.BackendsFromURLs("A", "B", "C")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	seen := map[string]int{}
+	for i := 0; i < 300; i++ {
+		got, _ := l.PickBackend(nil)
+		seen[got]++
+	}
+	for _, b := range []string{"A", "B", "C"} {
+		if seen[b] == 0 {
+			t.Errorf("backend %s never picked among idle backends: %v", b, seen)
+		}
+	}
+}
+
+func TestLeastConnections_RequestLifecycle(t *testing.T) {
+	l, err := NewLeastConnections(&utils.Service{Backends: utils.BackendsFromURLs("A", "B")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l.RequestStarted("A")
+	if got, _ := l.PickBackend(nil); got != "B" {
+		t.Errorf("PickBackend() = %v, want B while A is busy", got)
+	}
+	l.RequestFinished("A", http_proxies.RequestResult{})
+	l.RequestStarted("B")
+	if got, _ := l.PickBackend(nil); got != "A" {
+		t.Errorf("PickBackend() = %v, want A once it finished", got)
+	}
+}
+
+func TestLeastConnections_SetHealthyBackends(t *testing.T) {
+	l, err := NewLeastConnections(&utils.Service{Backends: utils.BackendsFromURLs("A", "B")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l.RequestStarted("B")
+	l.SetHealthyBackends([]string{"B"})
+	if got, _ := l.PickBackend(nil); got != "B" {
+		t.Errorf("PickBackend() = %v, want only healthy backend B", got)
+	}
+	l.SetHealthyBackends([]string{})
+	if _, err := l.PickBackend(nil); err == nil {
+		t.Errorf("expected error with no healthy backends")
+	}
+}
@@ -0,0 +1,285 @@
+package balancers
+
+import (
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultConsecutiveFailures = 5
+	DefaultOutlierMinRequests  = 20
+	DefaultOutlierInterval     = 10 * time.Second
+	DefaultBaseEjection        = 30 * time.Second
+	DefaultMaxEjection         = 5 * time.Minute
+	DefaultMaxEjectionPercent  = 10
+)
+
+// OutlierDetector ejects backends that fail live traffic even though they pass their health checks. It
+// observes every proxied request and, as a Policy, keeps ejected backends out of the healthy set until their
+// ejection expires. At most maxEjected backends are out at once, so a pool-wide problem can't eject everything.
+type OutlierDetector struct {
+	serviceName         string
+	observer            http_proxies.RequestObserver // the wrapped strategy's observer, if any
+	consecutiveFailures int64
+	rateMargin          float64
+	minRequests         int64
+	interval            time.Duration
+	baseEjection        time.Duration
+	maxEjection         time.Duration
+	maxEjected          int
+	stats               map[string]*outlierStats
+	order               []string
+	windowStart         atomic.Int64 // unix nanos of the current rate interval
+	now                 func() time.Time
+	afterFunc           func(time.Duration, func())
+	onChange            atomic.Pointer[func()]
+	refresh             chan struct{} // pending onChange call, coalesced; see changed
+	startRefresh        sync.Once
+
+	mu      sync.Mutex
+	ejected atomic.Pointer[map[string]time.Time] // ejected backend -> ejected until; replaced under mu
+}
+
+type outlierStats struct {
+	consecutive atomic.Int64
+	requests    atomic.Int64 // in the current interval
+	failures    atomic.Int64
+	ejections   int // recent ejections, decays by one per interval spent in rotation; guarded by mu
+}
+
+// NewOutlierDetector returns nil when the service doesn't configure outlier_detection.
+func NewOutlierDetector(service *utils.Service, observer http_proxies.RequestObserver) (*OutlierDetector, error) {
+	cfg := service.OutlierDetection
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.ConsecutiveFailures < 0 || cfg.MinRequests < 0 || cfg.IntervalMs < 0 || cfg.BaseEjectionMs < 0 || cfg.MaxEjectionMs < 0 {
+		return nil, errors.New("outlier_detection values must not be negative")
+	}
+	if cfg.FailureRateMargin < 0 || cfg.FailureRateMargin > 1 {
+		return nil, errors.New("outlier_detection failure_rate_margin must be between 0 and 1")
+	}
+	if cfg.MaxEjectionPercent < 0 || cfg.MaxEjectionPercent > 100 {
+		return nil, errors.New("outlier_detection max_ejection_percent must be between 0 and 100")
+	}
+
+	backends := service.BackendURLs()
+	o := &OutlierDetector{
+		serviceName:         service.Name,
+		observer:            observer,
+		consecutiveFailures: orDefault(int64(cfg.ConsecutiveFailures), DefaultConsecutiveFailures),
+		rateMargin:          cfg.FailureRateMargin,
+		minRequests:         orDefault(cfg.MinRequests, DefaultOutlierMinRequests),
+		interval:            time.Duration(orDefault(cfg.IntervalMs, DefaultOutlierInterval.Milliseconds())) * time.Millisecond,
+		baseEjection:        time.Duration(orDefault(cfg.BaseEjectionMs, DefaultBaseEjection.Milliseconds())) * time.Millisecond,
+		maxEjection:         time.Duration(orDefault(cfg.MaxEjectionMs, DefaultMaxEjection.Milliseconds())) * time.Millisecond,
+		stats:               make(map[string]*outlierStats, len(backends)),
+		order:               backends,
+		now:                 time.Now,
+		afterFunc:           func(d time.Duration, f func()) { time.AfterFunc(d, f) },
+		refresh:             make(chan struct{}, 1),
+	}
+	if o.maxEjection < o.baseEjection {
+		return nil, errors.New("outlier_detection max_ejection_ms must be at least base_ejection_ms")
+	}
+	percent := cfg.MaxEjectionPercent
+	if percent == 0 {
+		percent = DefaultMaxEjectionPercent
+	}
+	o.maxEjected = max(int(float64(len(backends))*percent/100), 1)
+	for _, b := range backends {
+		o.stats[b] = &outlierStats{}
+	}
+	o.ejected.Store(&map[string]time.Time{})
+	o.windowStart.Store(o.now().UnixNano())
+	return o, nil
+}
+
+func orDefault(v, def int64) int64 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// OnChange registers fn to be called after a backend is ejected or returned, so the healthy set can be
+// recomputed without waiting for the next health check. fn runs on a goroutine of its own, never on the
+// request that triggered the change; changes arriving while it runs are folded into one more call.
+func (o *OutlierDetector) OnChange(fn func()) {
+	o.onChange.Store(&fn)
+	o.startRefresh.Do(func() { go o.runRefresh() })
+}
+
+func (o *OutlierDetector) runRefresh() {
+	for range o.refresh {
+		if fn := o.onChange.Load(); fn != nil {
+			(*fn)()
+		}
+	}
+}
+
+func (o *OutlierDetector) RequestStarted(backend string) {
+	if o.observer != nil {
+		o.observer.RequestStarted(backend)
+	}
+}
+
+func (o *OutlierDetector) RequestFinished(backend string, result http_proxies.RequestResult) {
+	if o.observer != nil {
+		o.observer.RequestFinished(backend, result)
+	}
+	stats, ok := o.stats[backend]
+	if !ok {
+		return
+	}
+
+	stats.requests.Add(1)
+	if result.Failed() {
+		stats.failures.Add(1)
+		// Not just at the threshold: an ejection refused by max_ejection_percent is retried on every further
+		// failure, and eject ignores backends that are already out. Only the first refusal is logged.
+		if n := stats.consecutive.Add(1); n >= o.consecutiveFailures {
+			if full := o.eject(backend, "consecutive failures"); full > 0 && n == o.consecutiveFailures {
+				o.logRefused(backend, "consecutive failures", full)
+			}
+		}
+	} else {
+		stats.consecutive.Store(0)
+	}
+
+	now := o.now()
+	start := o.windowStart.Load()
+	if now.UnixNano()-start >= int64(o.interval) && o.windowStart.CompareAndSwap(start, now.UnixNano()) {
+		o.evaluateInterval()
+	}
+}
+
+// evaluateInterval ejects the backends whose failure rate over the last interval exceeds the pool's by more
+// than the margin, then starts a new interval.
+func (o *OutlierDetector) evaluateInterval() {
+	var outliers []string
+	var poolRequests, poolFailures int64
+	requests := make(map[string]int64, len(o.stats))
+	failures := make(map[string]int64, len(o.stats))
+	for backend, stats := range o.stats {
+		requests[backend] = stats.requests.Swap(0)
+		failures[backend] = stats.failures.Swap(0)
+		if requests[backend] >= o.minRequests {
+			poolRequests += requests[backend]
+			poolFailures += failures[backend]
+		}
+	}
+	if o.rateMargin > 0 && poolRequests > 0 {
+		poolRate := float64(poolFailures) / float64(poolRequests)
+		for _, backend := range o.order {
+			if requests[backend] < o.minRequests {
+				continue
+			}
+			if rate := float64(failures[backend]) / float64(requests[backend]); rate > poolRate+o.rateMargin {
+				outliers = append(outliers, backend)
+			}
+		}
+	}
+
+	o.mu.Lock()
+	ejected := *o.ejected.Load()
+	for backend, stats := range o.stats {
+		if _, out := ejected[backend]; !out && stats.ejections > 0 {
+			stats.ejections--
+		}
+	}
+	o.mu.Unlock()
+
+	for _, backend := range outliers {
+		if full := o.eject(backend, "failure rate above the pool"); full > 0 {
+			o.logRefused(backend, "failure rate above the pool", full)
+		}
+	}
+}
+
+// eject takes backend out of rotation. When max_ejection_percent doesn't allow another ejection it returns
+// the number of backends already ejected, and 0 otherwise.
+func (o *OutlierDetector) eject(backend, reason string) int {
+	o.mu.Lock()
+	current := *o.ejected.Load()
+	if _, out := current[backend]; out {
+		o.mu.Unlock()
+		return 0
+	}
+	if len(current) >= o.maxEjected {
+		o.mu.Unlock()
+		return len(current)
+	}
+	stats := o.stats[backend]
+	duration := o.baseEjection << min(stats.ejections, 30)
+	if duration > o.maxEjection || duration <= 0 {
+		duration = o.maxEjection
+	}
+	stats.ejections++
+	next := make(map[string]time.Time, len(current)+1)
+	for b, until := range current {
+		next[b] = until
+	}
+	next[backend] = o.now().Add(duration)
+	o.ejected.Store(&next)
+	o.mu.Unlock()
+
+	log.Printf("Service %s: ejecting backend %s for %v (%s)", o.serviceName, backend, duration, reason)
+	o.afterFunc(duration, func() { o.restore(backend) })
+	o.changed()
+	return 0
+}
+
+func (o *OutlierDetector) logRefused(backend, reason string, ejected int) {
+	log.Printf("Service %s: not ejecting backend %s (%s), %d backends already ejected",
+		o.serviceName, backend, reason, ejected)
+}
+
+func (o *OutlierDetector) restore(backend string) {
+	o.mu.Lock()
+	current := *o.ejected.Load()
+	next := make(map[string]time.Time, len(current))
+	for b, until := range current {
+		if b != backend {
+			next[b] = until
+		}
+	}
+	o.ejected.Store(&next)
+	stats := o.stats[backend]
+	stats.consecutive.Store(0)
+	o.mu.Unlock()
+
+	log.Printf("Service %s: returning backend %s to rotation", o.serviceName, backend)
+	o.changed()
+}
+
+// changed schedules a call of the OnChange function without blocking; one pending call covers any number
+// of changes, as it reads the ejections current when it runs.
+func (o *OutlierDetector) changed() {
+	select {
+	case o.refresh <- struct{}{}:
+	default:
+	}
+}
+
+func (o *OutlierDetector) Apply(eligible, healthy []string) ([]string, []string) {
+	ejected := *o.ejected.Load()
+	if len(ejected) == 0 {
+		return eligible, healthy
+	}
+	out := make(map[string]bool, len(ejected))
+	for b := range ejected {
+		out[b] = true
+	}
+	return eligible, exclude(healthy, out)
+}
+
+// Status reports each ejected backend with the time it returns to rotation.
+func (o *OutlierDetector) Status(status map[string]any) {
+	status["ejected"] = *o.ejected.Load()
+}
@@ -0,0 +1,184 @@
+package balancers
+
+import (
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestOutlierDetector returns a detector on a fake clock; restores are queued instead of scheduled.
+func newTestOutlierDetector(t *testing.T, cfg utils.OutlierDetectionConfig, backends ...string) (*OutlierDetector, *time.Time, *[]time.Duration, *[]func()) {
+	t.Helper()
+	o, err := NewOutlierDetector(&utils.Service{Name: "svc", Backends: utils.BackendsFromURLs(backends...), OutlierDetection: &cfg}, nil)
+	if err != nil {
+		t.Fatalf("NewOutlierDetector() unexpected error: %v", err)
+	}
+	now := time.Unix(0, 0)
+	o.now = func() time.Time { return now }
+	o.windowStart.Store(now.UnixNano())
+	var durations []time.Duration
+	var restores []func()
+	o.afterFunc = func(d time.Duration, f func()) {
+		durations = append(durations, d)
+		restores = append(restores, f)
+	}
+	return o, &now, &durations, &restores
+}
+
+var (
+	serverErr = http_proxies.RequestResult{StatusCode: 503}
+	okResult  = http_proxies.RequestResult{StatusCode: 200}
+	connErr   = http_proxies.RequestResult{Err: errors.New("connection refused")}
+)
+
+func activeBackends(o *OutlierDetector, healthy ...string) []string {
+	_, out := o.Apply(healthy, healthy)
+	return out
+}
+
+func TestOutlierDetector_ConsecutiveFailures(t *testing.T) {
+	o, _, durations, restores := newTestOutlierDetector(t, utils.OutlierDetectionConfig{
+		ConsecutiveFailures: 3, BaseEjectionMs: 1000, MaxEjectionMs: 3000, MaxEjectionPercent: 50,
+	}, "A", "B", "C", "D")
+
+	// A success in between resets the streak.
+	for _, r := range []http_proxies.RequestResult{serverErr, connErr, okResult, serverErr, serverErr} {
+		o.RequestFinished("A", r)
+	}
+	if got := activeBackends(o, "A", "B"); !reflect.DeepEqual(got, []string{"A", "B"}) {
+		t.Fatalf("active = %v, want A still in rotation", got)
+	}
+	o.RequestFinished("A", connErr)
+	if got := activeBackends(o, "A", "B"); !reflect.DeepEqual(got, []string{"B"}) {
+		t.Fatalf("active = %v, want A ejected after 3 consecutive failures", got)
+	}
+
+	// Every ejection of the same backend doubles, up to max_ejection_ms.
+	for i := 0; i < 2; i++ {
+		(*restores)[len(*restores)-1]()
+		if got := activeBackends(o, "A", "B"); !reflect.DeepEqual(got, []string{"A", "B"}) {
+			t.Fatalf("active = %v, want A restored", got)
+		}
+		for j := 0; j < 3; j++ {
+			o.RequestFinished("A", serverErr)
+		}
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+	if !reflect.DeepEqual(*durations, want) {
+		t.Errorf("ejection durations = %v, want %v", *durations, want)
+	}
+}
+
+func TestOutlierDetector_MaxEjectionPercent(t *testing.T) {
+	o, _, _, _ := newTestOutlierDetector(t, utils.OutlierDetectionConfig{ConsecutiveFailures: 1}, "A", "B", "C")
+
+	// 10% of three backends rounds down, but one backend can always be ejected.
+	o.RequestFinished("A", serverErr)
+	o.RequestFinished("B", serverErr)
+	if got := activeBackends(o, "A", "B", "C"); !reflect.DeepEqual(got, []string{"B", "C"}) {
+		t.Errorf("active = %v, want only A ejected", got)
+	}
+}
+
+func TestOutlierDetector_EjectsOnceCapFreesUp(t *testing.T) {
+	o, _, _, restores := newTestOutlierDetector(t, utils.OutlierDetectionConfig{ConsecutiveFailures: 2}, "A", "B", "C")
+
+	o.RequestFinished("A", serverErr)
+	o.RequestFinished("A", serverErr)
+	// The cap of one ejected backend refuses B at its threshold and on every failure after it.
+	for i := 0; i < 3; i++ {
+		o.RequestFinished("B", serverErr)
+	}
+	if got := activeBackends(o, "A", "B", "C"); !reflect.DeepEqual(got, []string{"B", "C"}) {
+		t.Fatalf("active = %v, want only A ejected while the cap is reached", got)
+	}
+
+	(*restores)[0]()
+	o.RequestFinished("B", serverErr)
+	if got := activeBackends(o, "A", "B", "C"); !reflect.DeepEqual(got, []string{"A", "C"}) {
+		t.Errorf("active = %v, want B ejected by its next failure once A's slot freed up", got)
+	}
+}
+
+func TestOutlierDetector_FailureRate(t *testing.T) {
+	o, now, _, _ := newTestOutlierDetector(t, utils.OutlierDetectionConfig{
+		ConsecutiveFailures: 1000, FailureRateMargin: 0.2, MinRequests: 10, IntervalMs: 1000,
+	}, "A", "B", "C", "D")
+
+	// A fails half its requests, the others 10%; C has too few requests to be judged.
+	for i := 0; i < 20; i++ {
+		r := okResult
+		if i%2 == 0 {
+			r = serverErr
+		}
+		o.RequestFinished("A", r)
+		r = okResult
+		if i%10 == 0 {
+			r = serverErr
+		}
+		o.RequestFinished("B", r)
+		o.RequestFinished("D", r)
+	}
+	o.RequestFinished("C", serverErr)
+	if got := activeBackends(o, "A", "B", "C", "D"); len(got) != 4 {
+		t.Fatalf("active = %v, want no ejection before the interval ends", got)
+	}
+
+	*now = now.Add(time.Second)
+	o.RequestFinished("B", okResult)
+	if got := activeBackends(o, "A", "B", "C", "D"); !reflect.DeepEqual(got, []string{"B", "C", "D"}) {
+		t.Errorf("active = %v, want A ejected for its failure rate", got)
+	}
+}
+
+func TestOutlierDetector_RefreshesFiltered(t *testing.T) {
+	o, _, _, restores := newTestOutlierDetector(t, utils.OutlierDetectionConfig{ConsecutiveFailures: 1, MaxEjectionPercent: 50}, "A", "B")
+	filtered := newTestFiltered(t, &utils.Service{Backends: utils.BackendsFromURLs("A", "B")}, o)
+	refreshed := make(chan struct{}, 1)
+	o.OnChange(func() {
+		filtered.Refresh()
+		refreshed <- struct{}{}
+	})
+	waitRefresh := func() {
+		t.Helper()
+		select {
+		case <-refreshed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("OnChange function was not called")
+		}
+	}
+
+	o.RequestFinished("B", connErr)
+	waitRefresh()
+	if !reflect.DeepEqual(*filtered.active.Load(), []string{"A"}) {
+		t.Fatalf("strategy healthy = %v, want B ejected without waiting for a health check", *filtered.active.Load())
+	}
+	filtered.SetHealthyBackends([]string{"A", "B"})
+	if !reflect.DeepEqual(*filtered.active.Load(), []string{"A"}) {
+		t.Fatalf("strategy healthy = %v, want B kept out while ejected", *filtered.active.Load())
+	}
+	(*restores)[0]()
+	waitRefresh()
+	if !reflect.DeepEqual(*filtered.active.Load(), []string{"A", "B"}) {
+		t.Errorf("strategy healthy = %v, want B back after its ejection", *filtered.active.Load())
+	}
+}
+
+func TestNewOutlierDetector_Validation(t *testing.T) {
+	for _, cfg := range []utils.OutlierDetectionConfig{
+		{ConsecutiveFailures: -1},
+		{FailureRateMargin: 1.5},
+		{MaxEjectionPercent: 120},
+		{BaseEjectionMs: 10000, MaxEjectionMs: 5000},
+	} {
+		if _, err := NewOutlierDetector(&utils.Service{Backends: utils.BackendsFromURLs("A"), OutlierDetection: &cfg}, nil); err == nil {
+			t.Errorf("NewOutlierDetector(%+v) expected error", cfg)
+		}
+	}
+	if o, err := NewOutlierDetector(&utils.Service{Backends: utils.BackendsFromURLs("A")}, nil); o != nil || err != nil {
+		t.Errorf("NewOutlierDetector() = %v, %v, want nil without config", o, err)
+	}
+}
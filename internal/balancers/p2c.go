@@ -0,0 +1,200 @@
+package balancers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/balancers/inflight"
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	P2C_SCORE_IN_FLIGHT = "IN_FLIGHT"
+	P2C_SCORE_PEAK_EWMA = "PEAK_EWMA"
+
+	DefaultEWMADecay    = 10 * time.Second
+	DefaultErrorPenalty = time.Second
+)
+
+// PowerOfTwoChoices samples two distinct healthy backends at random and sends the request to the one with the
+// lower score. The score is either the in-flight request count or the peak-EWMA latency cost
+// (latency * (in-flight + 1)), fed back by the proxy through RequestStarted/RequestFinished.
+type PowerOfTwoChoices struct {
+	service      *utils.Service
+	usePeakEWMA  bool
+	errorPenalty time.Duration
+	inFlight     *inflight.Counter
+	latency      map[string]*peakEWMA
+	healthy      atomic.Pointer[[]string]
+}
+
+// peakEWMA is an exponentially weighted moving average of latency that jumps straight to any observation
+// above the current average, so a backend that suddenly slows down is avoided immediately and only regains
+// traffic as the average decays.
+type peakEWMA struct {
+	mu       sync.Mutex
+	decay    time.Duration
+	value    float64 // nanoseconds
+	lastSeen time.Time
+	now      func() time.Time
+}
+
+func NewPowerOfTwoChoices(service *utils.Service) (*PowerOfTwoChoices, error) {
+	backends := service.BackendURLs()
+	if len(backends) == 0 {
+		err := errors.New("no available backends")
+		return nil, err
+	}
+
+	usePeakEWMA := true
+	if raw, exists := service.StrategyConfig["score"]; exists {
+		score, ok := raw.(string)
+		if !ok {
+			return nil, errors.New("score must be a string")
+		}
+		switch strings.ToUpper(score) {
+		case P2C_SCORE_IN_FLIGHT:
+			usePeakEWMA = false
+		case P2C_SCORE_PEAK_EWMA:
+		default:
+			return nil, fmt.Errorf("unknown p2c score %q — use in_flight or peak_ewma", score)
+		}
+	}
+
+	decay := DefaultEWMADecay
+	if raw, exists := service.StrategyConfig["decay_ms"]; exists {
+		val, ok := raw.(int)
+		if !ok || val <= 0 {
+			return nil, errors.New("decay_ms must be a positive integer")
+		}
+		decay = time.Duration(val) * time.Millisecond
+	}
+
+	errorPenalty := DefaultErrorPenalty
+	if raw, exists := service.StrategyConfig["error_penalty_ms"]; exists {
+		val, ok := raw.(int)
+		if !ok || val < 0 {
+			return nil, errors.New("error_penalty_ms must be a non-negative integer")
+		}
+		errorPenalty = time.Duration(val) * time.Millisecond
+	}
+
+	latency := make(map[string]*peakEWMA, len(backends))
+	for _, backend := range backends {
+		latency[backend] = &peakEWMA{decay: decay, now: time.Now}
+	}
+
+	p := &PowerOfTwoChoices{
+		service:      service,
+		usePeakEWMA:  usePeakEWMA,
+		errorPenalty: errorPenalty,
+		inFlight:     inflight.NewCounter(backends),
+		latency:      latency,
+	}
+	healthy := append([]string(nil), backends...)
+	p.healthy.Store(&healthy)
+	return p, nil
+}
+
+func (p *PowerOfTwoChoices) PickBackend(*http.Request) (string, error) {
+	healthy := *p.healthy.Load()
+	n := len(healthy)
+	if n == 0 {
+		return "", utils.ErrNoHealthyBackends
+	}
+	if n == 1 {
+		return healthy[0], nil
+	}
+
+	i := rand.Intn(n)
+	j := rand.Intn(n - 1)
+	if j >= i {
+		j++
+	}
+	a, b := healthy[i], healthy[j]
+	if scoreA, scoreB := p.scores(a, b); scoreB < scoreA {
+		return b, nil
+	}
+	return a, nil
+}
+
+// scores returns the scores of the two sampled backends. A backend with no latency observations yet is scored
+// with the other one's latency, so a new backend competes on in-flight requests alone instead of being ranked
+// behind every backend that has been measured.
+func (p *PowerOfTwoChoices) scores(a, b string) (float64, float64) {
+	pendingA, pendingB := float64(p.inFlight.Load(a)), float64(p.inFlight.Load(b))
+	if !p.usePeakEWMA {
+		return pendingA, pendingB
+	}
+	latencyA, latencyB := p.latencyOf(a), p.latencyOf(b)
+	if latencyA == 0 || latencyB == 0 {
+		return pendingA, pendingB
+	}
+	return latencyA * (pendingA + 1), latencyB * (pendingB + 1)
+}
+
+func (p *PowerOfTwoChoices) latencyOf(backend string) float64 {
+	ewma, ok := p.latency[backend]
+	if !ok {
+		return 0
+	}
+	return ewma.get()
+}
+
+func (p *PowerOfTwoChoices) SetHealthyBackends(healthyBackends []string) {
+	if hasChanged := utils.HasBackendChanged(*p.healthy.Load(), healthyBackends); !hasChanged {
+		return
+	}
+	healthy := append([]string(nil), healthyBackends...)
+	p.healthy.Store(&healthy)
+}
+
+func (p *PowerOfTwoChoices) RequestStarted(backend string) {
+	p.inFlight.Inc(backend)
+}
+
+func (p *PowerOfTwoChoices) RequestFinished(backend string, result http_proxies.RequestResult) {
+	p.inFlight.Dec(backend)
+	ewma, ok := p.latency[backend]
+	if !ok {
+		return
+	}
+	latency := result.Latency
+	if result.Failed() && latency < p.errorPenalty {
+		latency = p.errorPenalty
+	}
+	ewma.observe(latency)
+}
+
+func (e *peakEWMA) observe(latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	rtt := float64(latency)
+	if rtt > e.value {
+		e.value = rtt
+	} else {
+		w := math.Exp(-float64(now.Sub(e.lastSeen)) / float64(e.decay))
+		e.value = e.value*w + rtt*(1-w)
+	}
+	e.lastSeen = now
+}
+
+// get returns the average decayed towards zero for the time since the last observation, so a backend that
+// stopped receiving traffic after a latency spike is eventually tried again.
+func (e *peakEWMA) get() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.value == 0 {
+		return 0
+	}
+	return e.value * math.Exp(-float64(e.now().Sub(e.lastSeen))/float64(e.decay))
+}
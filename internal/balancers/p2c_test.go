@@ -0,0 +1,150 @@
+package balancers
+
+import (
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestNewPowerOfTwoChoices_Config(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      map[string]any
+		expectError bool
+	}{
+		{name: "defaults", config: map[string]any{}},
+		{name: "in flight score", config: map[string]any{"score": "in_flight"}},
+		{name: "peak ewma score", config: map[string]any{"score": "peak_ewma", "decay_ms": 5000, "error_penalty_ms": 500}},
+		{name: "unknown score", config: map[string]any{"score": "fastest"}, expectError: true},
+		{name: "invalid decay", config: map[string]any{"decay_ms": 0}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPowerOfTwoChoices(&utils.Service{Backends: utils.BackendsFromURLs("A", "B"), StrategyConfig: tt.config})
+			if (err != nil) != tt.expectError {
+				t.Errorf("NewPowerOfTwoChoices() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestPowerOfTwoChoices_InFlightScore(t *testing.T) {
+	p, err := NewPowerOfTwoChoices(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B"),
+		StrategyConfig: map[string]any{"score": "in_flight"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.RequestStarted("A")
+	p.RequestStarted("A")
+	p.RequestStarted("B")
+
+	// With two backends both are always sampled, so the less loaded one must win.
+	for i := 0; i < 20; i++ {
+		if got, _ := p.PickBackend(nil); got != "B" {
+			t.Fatalf("PickBackend() = %v, want B", got)
+		}
+	}
+}
+
+func TestPowerOfTwoChoices_PeakEWMAPrefersFasterBackend(t *testing.T) {
+	p, err := NewPowerOfTwoChoices(&utils.Service{Backends: utils.BackendsFromURLs("A", "B")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Unix(0, 0)
+	for _, e := range p.latency {
+		e.now = func() time.Time { return now }
+	}
+
+	p.RequestStarted("A")
+	p.RequestFinished("A", http_proxies.RequestResult{StatusCode: 200, Latency: 200 * time.Millisecond})
+	p.RequestStarted("B")
+	p.RequestFinished("B", http_proxies.RequestResult{StatusCode: 200, Latency: 10 * time.Millisecond})
+
+	if got, _ := p.PickBackend(nil); got != "B" {
+		t.Errorf("PickBackend() = %v, want faster backend B", got)
+	}
+
+	// A failure on B is recorded as the error penalty, which makes A the cheaper choice.
+	p.RequestStarted("B")
+	p.RequestFinished("B", http_proxies.RequestResult{Err: errors.New("connection refused"), Latency: time.Millisecond})
+	if got, _ := p.PickBackend(nil); got != "A" {
+		t.Errorf("PickBackend() = %v, want A after B failed", got)
+	}
+}
+
+func TestPowerOfTwoChoices_PeakEWMAUnobservedBackendGetsTraffic(t *testing.T) {
+	p, err := NewPowerOfTwoChoices(&utils.Service{Backends: utils.BackendsFromURLs("A", "B")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Unix(0, 0)
+	for _, e := range p.latency {
+		e.now = func() time.Time { return now }
+	}
+
+	// A is measured and busy; B was just added and has no observations yet.
+	p.RequestStarted("A")
+	p.RequestFinished("A", http_proxies.RequestResult{StatusCode: 200, Latency: time.Millisecond})
+	p.RequestStarted("A")
+
+	if got, _ := p.PickBackend(nil); got != "B" {
+		t.Errorf("PickBackend() = %v, want the new backend B while A is busy", got)
+	}
+
+	// Idle and measured, A is at least as cheap as the unmeasured B.
+	p.RequestFinished("A", http_proxies.RequestResult{StatusCode: 200, Latency: time.Millisecond})
+	p.RequestStarted("B")
+	if got, _ := p.PickBackend(nil); got != "A" {
+		t.Errorf("PickBackend() = %v, want A while B has a request in flight", got)
+	}
+}
+
+func TestPeakEWMA(t *testing.T) {
+	now := time.Unix(0, 0)
+	e := &peakEWMA{decay: 10 * time.Second, now: func() time.Time { return now }}
+
+	e.observe(100 * time.Millisecond)
+	if got := time.Duration(e.get()); got != 100*time.Millisecond {
+		t.Errorf("first observation = %v, want 100ms", got)
+	}
+
+	e.observe(500 * time.Millisecond)
+	if got := time.Duration(e.get()); got != 500*time.Millisecond {
+		t.Errorf("peak observation = %v, want jump to 500ms", got)
+	}
+
+	now = now.Add(10 * time.Second)
+	e.observe(100 * time.Millisecond)
+	got := time.Duration(e.get())
+	if got <= 100*time.Millisecond || got >= 500*time.Millisecond {
+		t.Errorf("decayed average = %v, want between 100ms and 500ms", got)
+	}
+
+	now = now.Add(time.Minute)
+	if decayed := time.Duration(e.get()); decayed >= got/100 {
+		t.Errorf("idle average = %v, want it to decay towards zero", decayed)
+	}
+}
+
+func TestPowerOfTwoChoices_SetHealthyBackends(t *testing.T) {
+	p, err := NewPowerOfTwoChoices(&utils.Service{Backends: utils.BackendsFromURLs("A", "B", "C")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.SetHealthyBackends([]string{"C"})
+	for i := 0; i < 10; i++ {
+		if got, _ := p.PickBackend(nil); got != "C" {
+			t.Fatalf("PickBackend() = %v, want only healthy backend C", got)
+		}
+	}
+	p.SetHealthyBackends(nil)
+	if _, err := p.PickBackend(nil); err == nil {
+		t.Errorf("expected error with no healthy backends")
+	}
+}
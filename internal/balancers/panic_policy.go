@@ -0,0 +1,65 @@
+package balancers
+
+import (
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"log"
+	"sync/atomic"
+)
+
+// panicPolicy routes across every eligible backend, healthy or not, while the healthy fraction is below the
+// threshold. When most of a pool fails its checks at once the checks themselves are often what's broken, and
+// sending traffic to possibly unhealthy backends beats rejecting every request. It belongs after the tier and
+// zone policies, so the fraction is that of the backends they chose and panic mode never reaches past them: in
+// the backup tier it covers the backups and the healthy primaries, not the primaries that failed over.
+type panicPolicy struct {
+	serviceName string
+	threshold   float64
+	panicking   atomic.Bool
+	entered     atomic.Int64 // times panic mode was entered
+	healthyFrac atomic.Pointer[float64]
+}
+
+// NewPanicPolicy returns nil when the service doesn't set panic_threshold.
+func NewPanicPolicy(service *utils.Service) (Policy, error) {
+	if service.PanicThreshold < 0 || service.PanicThreshold > 1 {
+		return nil, fmt.Errorf("panic_threshold %v must be between 0 and 1", service.PanicThreshold)
+	}
+	if service.PanicThreshold == 0 {
+		return nil, nil
+	}
+	p := &panicPolicy{serviceName: service.Name, threshold: service.PanicThreshold}
+	full := 1.0
+	p.healthyFrac.Store(&full)
+	return p, nil
+}
+
+func (p *panicPolicy) Apply(eligible, healthy []string) ([]string, []string) {
+	if len(eligible) == 0 {
+		return eligible, healthy
+	}
+	fraction := float64(len(healthy)) / float64(len(eligible))
+	p.healthyFrac.Store(&fraction)
+
+	panicking := fraction < p.threshold
+	if p.panicking.Swap(panicking) != panicking {
+		if panicking {
+			p.entered.Add(1)
+			log.Printf("Service %s: PANIC MODE — %d/%d backends healthy (below %v), routing to all of them",
+				p.serviceName, len(healthy), len(eligible), p.threshold)
+		} else {
+			log.Printf("Service %s: leaving panic mode, %d/%d backends healthy",
+				p.serviceName, len(healthy), len(eligible))
+		}
+	}
+	if panicking {
+		return eligible, eligible
+	}
+	return eligible, healthy
+}
+
+func (p *panicPolicy) Status(status map[string]any) {
+	status["panic"] = p.panicking.Load()
+	status["panic_entered_total"] = p.entered.Load()
+	status["healthy_fraction"] = *p.healthyFrac.Load()
+}
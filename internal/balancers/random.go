@@ -5,23 +5,28 @@ import (
 	"github.com/aribhuiya/stormgate/internal/utils"
 	"math/rand"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
 type Random struct {
-	service utils.Service
-	seed    int32
+	service  utils.Service
+	backends atomic.Pointer[[]string] // healthy backends, replaced as a whole on every health change
+	seed     int32
 }
 
 func NewRandom(service *utils.Service, seed int32) (*Random, error) {
-	if len(service.Backends) == 0 {
+	backends := service.BackendURLs()
+	if len(backends) == 0 {
 		err := errors.New("no available backends")
 		return nil, err
 	}
-	return &Random{
+	r := &Random{
 		service: *service,
 		seed:    seed,
-	}, nil
+	}
+	r.backends.Store(&backends)
+	return r, nil
 }
 
 func NewRandomAutoSeed(service *utils.Service) (*Random, error) {
@@ -30,16 +35,18 @@ func NewRandomAutoSeed(service *utils.Service) (*Random, error) {
 }
 
 func (r *Random) PickBackend(request *http.Request) (string, error) {
-	if len(r.service.Backends) == 0 {
-		return "", errors.New("no healthy backends available")
+	backends := *r.backends.Load()
+	if len(backends) == 0 {
+		return "", utils.ErrNoHealthyBackends
 	}
-	idx := rand.Int() % len(r.service.Backends)
-	return r.service.Backends[idx], nil
+	idx := rand.Int() % len(backends)
+	return backends[idx], nil
 }
 
 func (r *Random) SetHealthyBackends(healthyBackends []string) {
-	if hasChanged := utils.HasBackendChanged(r.service.Backends, healthyBackends); !hasChanged {
+	if hasChanged := utils.HasBackendChanged(*r.backends.Load(), healthyBackends); !hasChanged {
 		return
 	}
-	r.service.Backends = healthyBackends
+	backends := append([]string(nil), healthyBackends...)
+	r.backends.Store(&backends)
 }
@@ -25,7 +25,7 @@ func TestRandom_PickBackend(t *testing.T) {
 			name: "Select random backend from 2 backends",
 			fields: fields{
 				service: utils.Service{
-					Backends: []string{"http://localhost:9001", "http://localhost:9002"},
+					Backends: utils.BackendsFromURLs("http://localhost:9001", "http://localhost:9002"),
 				},
 				seed: 42,
 			},
@@ -37,7 +37,7 @@ func TestRandom_PickBackend(t *testing.T) {
 			name: "Select random backend from single backend",
 			fields: fields{
 				service: utils.Service{
-					Backends: []string{"http://localhost:9001"},
+					Backends: utils.BackendsFromURLs("http://localhost:9001"),
 				},
 				seed: 7,
 			},
@@ -48,9 +48,9 @@ func TestRandom_PickBackend(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := &Random{
-				service: tt.fields.service,
-				seed:    tt.fields.seed,
+			r, err := NewRandom(&tt.fields.service, tt.fields.seed)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
 			}
 			got, err := r.PickBackend(tt.args.request)
 			if (err != nil) != tt.wantErr {
@@ -59,7 +59,7 @@ func TestRandom_PickBackend(t *testing.T) {
 			}
 			found := false
 			for _, b := range tt.fields.service.Backends {
-				if got == b {
+				if got == b.URL {
 					found = true
 					break
 				}
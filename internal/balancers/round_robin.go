@@ -8,35 +8,38 @@ import (
 )
 
 type RoundRobin struct {
-	counter atomic.Uint64 // for counting the total requests. thread-safe at CPU level
-	service *utils.Service
-	n       uint64
+	counter  atomic.Uint64 // for counting the total requests. thread-safe at CPU level
+	service  *utils.Service
+	backends atomic.Pointer[[]string] // healthy backends, replaced as a whole on every health change
 }
 
 func NewRoundRobin(service *utils.Service) (*RoundRobin, error) {
-	if len(service.Backends) == 0 {
+	backends := service.BackendURLs()
+	if len(backends) == 0 {
 		err := errors.New("no available backends")
 		return nil, err
 	}
-	return &RoundRobin{
+	r := &RoundRobin{
 		counter: atomic.Uint64{},
 		service: service,
-		n:       uint64(len(service.Backends)),
-	}, nil
+	}
+	r.backends.Store(&backends)
+	return r, nil
 }
 
 func (r *RoundRobin) PickBackend(*http.Request) (string, error) {
-	if r.n == 0 {
-		return "", errors.New("no healthy backends available")
+	backends := *r.backends.Load()
+	if len(backends) == 0 {
+		return "", utils.ErrNoHealthyBackends
 	}
-	index := (r.counter.Add(1) - 1) % r.n
-	return r.service.Backends[index], nil
+	index := (r.counter.Add(1) - 1) % uint64(len(backends))
+	return backends[index], nil
 }
 
 func (r *RoundRobin) SetHealthyBackends(healthyBackends []string) {
-	if hasChanged := utils.HasBackendChanged(r.service.Backends, healthyBackends); !hasChanged {
+	if hasChanged := utils.HasBackendChanged(*r.backends.Load(), healthyBackends); !hasChanged {
 		return
 	}
-	r.service.Backends = healthyBackends
-	r.n = uint64(len(healthyBackends))
+	backends := append([]string(nil), healthyBackends...)
+	r.backends.Store(&backends)
 }
@@ -8,7 +8,7 @@ import (
 
 func TestRoundRobin_PickBackend_MultipleCalls(t *testing.T) {
 	backends := []string{"http://localhost:9001", "http://localhost:9002", "http://localhost:9003"}
-	service := &utils.Service{Backends: backends}
+	service := &utils.Service{Backends: utils.BackendsFromURLs(backends...)}
 	rr, err := NewRoundRobin(service)
 	if err != nil {
 		t.Fatalf("unexpected error creating RoundRobin: %v", err)
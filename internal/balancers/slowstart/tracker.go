@@ -0,0 +1,126 @@
+package slowstart
+
+import (
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultMinWeightPercent = 10
+	DefaultAggression       = 1.0
+
+	// factorFloor keeps ramped weights positive, since balancers divide by them or normalise over their sum.
+	factorFloor = 1e-6
+)
+
+// Tracker remembers when each backend (re)joined the healthy set and scales its weight while it warms up.
+// Backends present at startup are treated as warm. A nil *Tracker is valid and never ramps, so balancers can
+// call it unconditionally.
+type Tracker struct {
+	window     time.Duration
+	minFactor  float64
+	aggression float64
+	now        func() time.Time
+	state      atomic.Pointer[trackerState] // replaced whole by Update, read lock-free by Factor
+}
+
+type trackerState struct {
+	healthy    map[string]bool
+	rampStart  map[string]time.Time // only backends that joined after startup
+	rampingEnd time.Time            // latest time any backend finishes ramping
+}
+
+// NewTracker returns nil when the service has no slow_start block.
+func NewTracker(service *utils.Service) (*Tracker, error) {
+	cfg := service.SlowStart
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.DurationMs <= 0 {
+		return nil, errors.New("slow_start duration_ms must be greater than 0")
+	}
+	minPercent := float64(DefaultMinWeightPercent)
+	if cfg.MinWeightPercent != nil {
+		minPercent = *cfg.MinWeightPercent
+	}
+	if minPercent < 0 || minPercent > 100 {
+		return nil, errors.New("slow_start min_weight_percent must be between 0 and 100")
+	}
+	aggression := cfg.Aggression
+	if aggression == 0 {
+		aggression = DefaultAggression
+	}
+	if aggression < 0 {
+		return nil, errors.New("slow_start aggression must not be negative (0 means the default of 1)")
+	}
+
+	t := &Tracker{
+		window:     time.Duration(cfg.DurationMs) * time.Millisecond,
+		minFactor:  math.Max(minPercent/100, factorFloor),
+		aggression: aggression,
+		now:        time.Now,
+	}
+	backends := service.BackendURLs()
+	healthy := make(map[string]bool, len(backends))
+	for _, b := range backends {
+		healthy[b] = true
+	}
+	t.state.Store(&trackerState{healthy: healthy, rampStart: map[string]time.Time{}})
+	return t, nil
+}
+
+// Update records the new healthy set; backends that were not healthy before start ramping now.
+func (t *Tracker) Update(healthyBackends []string) {
+	if t == nil {
+		return
+	}
+	now := t.now()
+	prev := t.state.Load()
+	next := &trackerState{
+		healthy:    make(map[string]bool, len(healthyBackends)),
+		rampStart:  make(map[string]time.Time),
+		rampingEnd: prev.rampingEnd,
+	}
+	for _, b := range healthyBackends {
+		next.healthy[b] = true
+		start, ramping := prev.rampStart[b]
+		if !prev.healthy[b] {
+			start, ramping = now, true
+		}
+		if ramping && now.Sub(start) < t.window {
+			next.rampStart[b] = start
+			if end := start.Add(t.window); end.After(next.rampingEnd) {
+				next.rampingEnd = end
+			}
+		}
+	}
+	t.state.Store(next)
+}
+
+// Factor returns the fraction of its configured weight the backend should currently receive, in [min, 1].
+func (t *Tracker) Factor(backend string) float64 {
+	if t == nil {
+		return 1
+	}
+	start, ok := t.state.Load().rampStart[backend]
+	if !ok {
+		return 1
+	}
+	elapsed := t.now().Sub(start)
+	if elapsed >= t.window {
+		return 1
+	}
+	f := math.Pow(float64(elapsed)/float64(t.window), 1/t.aggression)
+	return math.Max(t.minFactor, f)
+}
+
+// Ramping reports whether any backend is still warming up.
+func (t *Tracker) Ramping() bool {
+	if t == nil {
+		return false
+	}
+	return t.now().Before(t.state.Load().rampingEnd)
+}
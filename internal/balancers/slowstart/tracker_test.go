@@ -0,0 +1,121 @@
+package slowstart
+
+import (
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewTracker_Config(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         *utils.SlowStartConfig
+		expectNil   bool
+		expectError bool
+	}{
+		{name: "not configured", cfg: nil, expectNil: true},
+		{name: "defaults", cfg: &utils.SlowStartConfig{DurationMs: 1000}},
+		{name: "missing duration", cfg: &utils.SlowStartConfig{}, expectError: true},
+		{name: "floor above 100%", cfg: &utils.SlowStartConfig{DurationMs: 1000, MinWeightPercent: percent(150)}, expectError: true},
+		{name: "zero floor", cfg: &utils.SlowStartConfig{DurationMs: 1000, MinWeightPercent: percent(0)}},
+		{name: "negative aggression", cfg: &utils.SlowStartConfig{DurationMs: 1000, Aggression: -1}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, err := NewTracker(&utils.Service{Backends: utils.BackendsFromURLs("A"), SlowStart: tt.cfg})
+			if (err != nil) != tt.expectError {
+				t.Fatalf("NewTracker() error = %v, expectError %v", err, tt.expectError)
+			}
+			if !tt.expectError && (tracker == nil) != tt.expectNil {
+				t.Errorf("NewTracker() = %v, expectNil %v", tracker, tt.expectNil)
+			}
+		})
+	}
+}
+
+func TestTracker_LinearRamp(t *testing.T) {
+	tracker, now := newTestTracker(t, &utils.SlowStartConfig{DurationMs: 10000, MinWeightPercent: percent(10)})
+
+	if f := tracker.Factor("A"); f != 1 {
+		t.Errorf("backend present at startup has factor %v, want 1", f)
+	}
+
+	tracker.Update([]string{"A"}) // B goes down
+	tracker.Update([]string{"A", "B"})
+	if !tracker.Ramping() {
+		t.Fatalf("expected tracker to be ramping after B recovered")
+	}
+
+	steps := []struct {
+		elapsed time.Duration
+		want    float64
+	}{
+		{0, 0.1}, // clamped to the floor
+		{5 * time.Second, 0.5},
+		{9 * time.Second, 0.9},
+		{10 * time.Second, 1},
+	}
+	start := *now
+	for _, step := range steps {
+		*now = start.Add(step.elapsed)
+		if got := tracker.Factor("B"); math.Abs(got-step.want) > 1e-9 {
+			t.Errorf("factor after %v = %v, want %v", step.elapsed, got, step.want)
+		}
+	}
+	if tracker.Ramping() {
+		t.Errorf("expected ramp to be over")
+	}
+	if f := tracker.Factor("A"); f != 1 {
+		t.Errorf("backend that stayed healthy has factor %v, want 1", f)
+	}
+}
+
+func TestTracker_ZeroFloor(t *testing.T) {
+	tracker, now := newTestTracker(t, &utils.SlowStartConfig{DurationMs: 10000, MinWeightPercent: percent(0)})
+	tracker.Update([]string{"A"})
+	tracker.Update([]string{"A", "B"})
+
+	if got := tracker.Factor("B"); got <= 0 || got > 1e-3 {
+		t.Errorf("factor right after recovery with a 0%% floor = %v, want just above 0", got)
+	}
+	*now = now.Add(time.Second)
+	if got := tracker.Factor("B"); math.Abs(got-0.1) > 1e-9 {
+		t.Errorf("factor after 10%% of the window = %v, want 0.1", got)
+	}
+}
+
+func TestTracker_Aggression(t *testing.T) {
+	tracker, now := newTestTracker(t, &utils.SlowStartConfig{DurationMs: 10000, MinWeightPercent: percent(1), Aggression: 2})
+	tracker.Update(nil)
+	tracker.Update([]string{"A"})
+
+	*now = now.Add(2500 * time.Millisecond)
+	if got := tracker.Factor("A"); math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("factor at 25%% of the window with aggression 2 = %v, want 0.5", got)
+	}
+}
+
+func TestTracker_Nil(t *testing.T) {
+	var tracker *Tracker
+	tracker.Update([]string{"A"})
+	if tracker.Ramping() || tracker.Factor("A") != 1 {
+		t.Errorf("nil tracker must never ramp")
+	}
+}
+
+func newTestTracker(t *testing.T, cfg *utils.SlowStartConfig) (*Tracker, *time.Time) {
+	t.Helper()
+	tracker, err := NewTracker(&utils.Service{Backends: utils.BackendsFromURLs("A", "B"), SlowStart: cfg})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Unix(1000, 0)
+	tracker.now = func() time.Time { return now }
+	return tracker, &now
+}
+
+func percent(v float64) *float64 {
+	return &v
+}
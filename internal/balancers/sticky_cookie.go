@@ -0,0 +1,126 @@
+package balancers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/cookies"
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"log"
+	"net/http"
+	"sync/atomic"
+)
+
+const DefaultStickyCookieName = "stormgate-sticky"
+
+// StickyCookie pins clients to a backend with a cookie naming it. The cookie holds an opaque, HMAC-signed
+// backend id rather than the address, so clients can neither read nor forge it. A request whose pinned backend
+// is healthy and below its max_connections goes straight there; any other request is balanced by the wrapped
+// strategy and (re-)pinned to the backend it picks.
+type StickyCookie struct {
+	Balancer
+	observer http_proxies.RequestObserver // the wrapped strategy's observer, if any
+	cookie   *cookies.Spec
+	idOf     map[string]string // backend -> cookie id
+	byID     map[string]string // cookie id -> backend
+	healthy  atomic.Pointer[map[string]bool]
+	atLimit  func(backend string) bool // set by ConnLimited when backends have max_connections
+}
+
+func NewStickyCookie(inner Balancer, service *utils.Service) (*StickyCookie, error) {
+	cfg := service.StickyCookie.CookieConfig
+	if service.StickyCookie.Secret != "" {
+		cfg.Secrets = append([]string{service.StickyCookie.Secret}, cfg.Secrets...)
+	}
+	spec, err := cookies.NewSpec(cfg, DefaultStickyCookieName, service.PathPrefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("sticky_cookie: %w", err)
+	}
+	if !spec.Signed() {
+		log.Printf("Service %s: sticky_cookie has no secret, using a random one; clients are re-pinned after restarts", service.Name)
+		key := make([]byte, 32)
+		_, _ = rand.Read(key)
+		spec = spec.WithSigner(cookies.NewSigner(key))
+	}
+
+	backends := service.BackendURLs()
+	s := &StickyCookie{
+		Balancer: inner,
+		cookie:   spec,
+		idOf:     make(map[string]string, len(backends)),
+		byID:     make(map[string]string, len(backends)),
+	}
+	s.observer, _ = inner.(http_proxies.RequestObserver)
+	healthy := make(map[string]bool, len(backends))
+	for _, backend := range backends {
+		sum := sha256.Sum256([]byte(backend))
+		id := hex.EncodeToString(sum[:8])
+		s.idOf[backend] = id
+		s.byID[id] = backend
+		healthy[backend] = true
+	}
+	s.healthy.Store(&healthy)
+	return s, nil
+}
+
+func (s *StickyCookie) PickBackend(req *http.Request) (string, error) {
+	if backend, ok := s.pinned(req); ok {
+		return backend, nil
+	}
+	backend, err := s.Balancer.PickBackend(req)
+	if err != nil {
+		return "", err
+	}
+	s.cookie.Issue(req, s.idOf[backend])
+	return backend, nil
+}
+
+// pinned returns the backend named by the request's cookie, if the cookie is authentic and the backend healthy
+// and not at its connection limit.
+func (s *StickyCookie) pinned(req *http.Request) (string, bool) {
+	id, ok := s.cookie.Read(req)
+	if !ok {
+		return "", false
+	}
+	backend, ok := s.byID[id]
+	if !ok || !(*s.healthy.Load())[backend] {
+		return "", false
+	}
+	if s.atLimit != nil && s.atLimit(backend) {
+		return "", false
+	}
+	return backend, true
+}
+
+func (s *StickyCookie) setAtLimit(atLimit func(backend string) bool) {
+	s.atLimit = atLimit
+}
+
+func (s *StickyCookie) SetHealthyBackends(healthyBackends []string) {
+	healthy := make(map[string]bool, len(healthyBackends))
+	for _, backend := range healthyBackends {
+		healthy[backend] = true
+	}
+	s.healthy.Store(&healthy)
+	s.Balancer.SetHealthyBackends(healthyBackends)
+}
+
+func (s *StickyCookie) RequestStarted(backend string) {
+	if s.observer != nil {
+		s.observer.RequestStarted(backend)
+	}
+}
+
+func (s *StickyCookie) RequestFinished(backend string, result http_proxies.RequestResult) {
+	if s.observer != nil {
+		s.observer.RequestFinished(backend, result)
+	}
+}
+
+func (s *StickyCookie) Status() map[string]any {
+	status := innerStatus(s.Balancer)
+	status["sticky_cookie"] = s.cookie.Name
+	return status
+}
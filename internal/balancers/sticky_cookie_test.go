@@ -0,0 +1,138 @@
+package balancers
+
+import (
+	"github.com/aribhuiya/stormgate/internal/cookies"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestSticky(t *testing.T) *StickyCookie {
+	t.Helper()
+	service := &utils.Service{
+		Name:         "svc",
+		PathPrefix:   "/app/",
+		Backends:     utils.BackendsFromURLs("http://a:9001", "http://b:9002", "http://c:9003"),
+		StickyCookie: &utils.StickyCookieConfig{Secret: "test-secret"},
+	}
+	inner, err := NewRoundRobin(service)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err := NewStickyCookie(inner, service)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+// pick runs one request through s with the given cookie, returning the backend and the cookie set, if any.
+func pick(t *testing.T, s *StickyCookie, cookie *http.Cookie) (string, *http.Cookie) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/app/", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	ctx, jar := cookies.NewContext(req.Context())
+	backend, err := s.PickBackend(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("PickBackend() unexpected error: %v", err)
+	}
+	set := jar.Cookies()
+	if len(set) == 0 {
+		return backend, nil
+	}
+	return backend, set[0]
+}
+
+func TestStickyCookie_PinsToBackend(t *testing.T) {
+	s := newTestSticky(t)
+
+	first, cookie := pick(t, s, nil)
+	if cookie == nil || cookie.Name != DefaultStickyCookieName || cookie.Path != "/app/" || !cookie.HttpOnly {
+		t.Fatalf("expected a sticky cookie on the first request, got %+v", cookie)
+	}
+	if strings.Contains(cookie.Value, "9001") || strings.Contains(cookie.Value, "http") {
+		t.Errorf("cookie value %q exposes the backend address", cookie.Value)
+	}
+
+	for i := 0; i < 5; i++ {
+		got, set := pick(t, s, cookie)
+		if got != first {
+			t.Errorf("PickBackend() = %v, want pinned %v", got, first)
+		}
+		if set != nil {
+			t.Errorf("unexpected cookie refresh for a valid pin: %+v", set)
+		}
+	}
+}
+
+func TestStickyCookie_RepinsWhenUnhealthy(t *testing.T) {
+	s := newTestSticky(t)
+	first, cookie := pick(t, s, nil)
+
+	var remaining []string
+	for _, b := range []string{"http://a:9001", "http://b:9002", "http://c:9003"} {
+		if b != first {
+			remaining = append(remaining, b)
+		}
+	}
+	s.SetHealthyBackends(remaining)
+
+	got, set := pick(t, s, cookie)
+	if got == first {
+		t.Fatalf("PickBackend() = %v, still pinned to an unhealthy backend", got)
+	}
+	if set == nil {
+		t.Fatalf("expected a new cookie after re-pinning")
+	}
+	if again, _ := pick(t, s, set); again != got {
+		t.Errorf("PickBackend() = %v, want new pin %v", again, got)
+	}
+}
+
+func TestStickyCookie_RepinsWhenAtMaxConnections(t *testing.T) {
+	s := newTestSticky(t)
+	service := &utils.Service{Backends: []utils.Backend{
+		{URL: "http://a:9001", MaxConnections: 1}, {URL: "http://b:9002", MaxConnections: 1}, {URL: "http://c:9003", MaxConnections: 1},
+	}}
+	b, err := NewConnLimited(s, service)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	limited := b.(*ConnLimited)
+
+	first, cookie := pick(t, s, nil)
+	limited.RequestStarted(first)
+
+	req := httptest.NewRequest("GET", "/app/", nil)
+	req.AddCookie(cookie)
+	ctx, jar := cookies.NewContext(req.Context())
+	got, err := limited.PickBackend(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("PickBackend() unexpected error with the pinned backend full: %v", err)
+	}
+	if got == first {
+		t.Fatalf("PickBackend() = %v, still pinned to a backend at max_connections", got)
+	}
+	set := jar.Cookies()
+	if len(set) == 0 {
+		t.Fatalf("expected a new cookie after re-pinning")
+	}
+	if again, _ := pick(t, s, set[0]); again != got {
+		t.Errorf("PickBackend() = %v, want new pin %v", again, got)
+	}
+}
+
+func TestStickyCookie_RejectsForgedCookie(t *testing.T) {
+	s := newTestSticky(t)
+	_, cookie := pick(t, s, nil)
+
+	id, _, _ := strings.Cut(cookie.Value, ".")
+	forged := &http.Cookie{Name: cookie.Name, Value: id + ".forged"}
+	if _, set := pick(t, s, forged); set == nil {
+		t.Errorf("forged cookie was accepted as a pin")
+	}
+}
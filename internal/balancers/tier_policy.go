@@ -0,0 +1,99 @@
+package balancers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"log"
+	"sync/atomic"
+)
+
+const (
+	TIER_PRIMARY = "primary"
+	TIER_BACKUP  = "backup"
+)
+
+// tierPolicy keeps traffic on the primary backends and fails over to the backups when too few primaries are
+// healthy, returning to the primaries as soon as enough recover.
+type tierPolicy struct {
+	serviceName string
+	primaries   map[string]bool
+	nPrimaries  int
+	threshold   float64
+	state       atomic.Pointer[tierState]
+}
+
+type tierState struct {
+	tier             string
+	healthyPrimaries int
+}
+
+func NewTierPolicy(service *utils.Service) (Policy, error) {
+	if service.FailoverThreshold < 0 || service.FailoverThreshold > 1 {
+		return nil, fmt.Errorf("failover_threshold %v must be between 0 and 1", service.FailoverThreshold)
+	}
+	all := service.AllBackends()
+	primaries := make(map[string]bool, len(all))
+	for _, b := range all {
+		if !b.Backup {
+			primaries[b.URL] = true
+		}
+	}
+	for _, b := range all {
+		if b.Backup && primaries[b.URL] {
+			return nil, fmt.Errorf("backend %s is listed as both primary and backup", b.URL)
+		}
+	}
+	if len(primaries) == 0 {
+		return nil, errors.New("no available backends")
+	}
+
+	t := &tierPolicy{
+		serviceName: service.Name,
+		primaries:   primaries,
+		nPrimaries:  len(primaries),
+		threshold:   service.FailoverThreshold,
+	}
+	t.state.Store(&tierState{tier: TIER_PRIMARY, healthyPrimaries: len(primaries)})
+	return t, nil
+}
+
+func (t *tierPolicy) Apply(eligible, healthy []string) ([]string, []string) {
+	healthyPrimaries := intersect(healthy, t.primaries)
+	next := &tierState{tier: TIER_PRIMARY, healthyPrimaries: len(healthyPrimaries)}
+	fraction := float64(len(healthyPrimaries)) / float64(t.nPrimaries)
+	if len(healthyPrimaries) == 0 || fraction < t.threshold {
+		next.tier = TIER_BACKUP
+	}
+
+	if prev := t.state.Load(); prev.tier != next.tier {
+		if next.tier == TIER_BACKUP {
+			log.Printf("Service %s: %d/%d primary backends healthy, failing over to backup tier",
+				t.serviceName, len(healthyPrimaries), t.nPrimaries)
+		} else {
+			log.Printf("Service %s: %d/%d primary backends healthy, returning to primary tier",
+				t.serviceName, len(healthyPrimaries), t.nPrimaries)
+		}
+	}
+	t.state.Store(next)
+
+	if next.tier == TIER_BACKUP {
+		// The remaining primaries and the backups share the traffic. Failed primaries are no longer candidates,
+		// so later stages (panic mode in particular) judge the backup tier by its own health.
+		failed := make(map[string]bool)
+		for primary := range t.primaries {
+			failed[primary] = true
+		}
+		for _, primary := range healthyPrimaries {
+			delete(failed, primary)
+		}
+		return exclude(eligible, failed), healthy
+	}
+	return intersect(eligible, t.primaries), healthyPrimaries
+}
+
+func (t *tierPolicy) Status(status map[string]any) {
+	state := t.state.Load()
+	status["tier"] = state.tier
+	status["healthy_primaries"] = state.healthyPrimaries
+}
@@ -0,0 +1,185 @@
+package balancers
+
+import (
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/balancers/slowstart"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// slowStartRebuildInterval bounds how often the alias table is rebuilt while slow start is ramping weights.
+const slowStartRebuildInterval = 250 * time.Millisecond
+
+// WeightedRandom picks backends at random in proportion to their weights using Vose's alias method,
+// so every pick is O(1) regardless of the number of backends.
+type WeightedRandom struct {
+	service     *utils.Service
+	weightOf    map[string]float64
+	slowStart   *slowstart.Tracker
+	table       atomic.Pointer[aliasTable]
+	rebuildMu   sync.Mutex
+	allBackends []string
+}
+
+// aliasTable splits the weights into n equally likely columns; column i yields backends[i] with probability
+// prob[i] and backends[alias[i]] otherwise.
+type aliasTable struct {
+	backends []string
+	prob     []float64
+	alias    []int
+	builtAt  time.Time
+	ramped   bool // built while some backend was below its full weight
+}
+
+func NewWeightedRandom(service *utils.Service) (*WeightedRandom, error) {
+	backends := service.BackendURLs()
+	if len(backends) == 0 {
+		err := errors.New("no available backends")
+		return nil, err
+	}
+	weights, err := utils.ParseWeights(service)
+	if err != nil {
+		return nil, err
+	}
+	if weights == nil {
+		return nil, errors.New("weights missing or malformed")
+	}
+	slowStart, err := slowstart.NewTracker(service)
+	if err != nil {
+		return nil, err
+	}
+
+	weightOf := make(map[string]float64, len(backends))
+	for i, backend := range backends {
+		weightOf[backend] = float64(weights[i])
+	}
+
+	w := &WeightedRandom{
+		service:     service,
+		weightOf:    weightOf,
+		slowStart:   slowStart,
+		allBackends: backends,
+	}
+	w.table.Store(w.buildTable(backends))
+	return w, nil
+}
+
+func (w *WeightedRandom) PickBackend(*http.Request) (string, error) {
+	table := w.table.Load()
+	if w.outdated(table) {
+		table = w.rebuildForSlowStart(table)
+	}
+	n := len(table.backends)
+	if n == 0 {
+		return "", utils.ErrNoHealthyBackends
+	}
+
+	i := rand.Intn(n)
+	if rand.Float64() < table.prob[i] {
+		return table.backends[i], nil
+	}
+	return table.backends[table.alias[i]], nil
+}
+
+// outdated reports whether table lags behind the slow-start ramp: while ramping it is refreshed every
+// slowStartRebuildInterval, and a table built mid-ramp is rebuilt once more after the ramp so the full weights
+// are restored.
+func (w *WeightedRandom) outdated(table *aliasTable) bool {
+	if w.slowStart.Ramping() {
+		return time.Since(table.builtAt) > slowStartRebuildInterval
+	}
+	return table.ramped
+}
+
+// rebuildForSlowStart refreshes the table with the current ramped weights. Only one caller rebuilds at a time;
+// the others keep using the slightly stale table.
+func (w *WeightedRandom) rebuildForSlowStart(stale *aliasTable) *aliasTable {
+	if !w.rebuildMu.TryLock() {
+		return stale
+	}
+	defer w.rebuildMu.Unlock()
+	if current := w.table.Load(); current != stale {
+		return current
+	}
+	fresh := w.buildTable(stale.backends)
+	w.table.Store(fresh)
+	return fresh
+}
+
+func (w *WeightedRandom) SetHealthyBackends(healthyBackends []string) {
+	if hasChanged := utils.HasBackendChanged(w.table.Load().backends, healthyBackends); !hasChanged {
+		return
+	}
+	w.slowStart.Update(healthyBackends)
+
+	healthySet := make(map[string]bool, len(healthyBackends))
+	for _, h := range healthyBackends {
+		healthySet[h] = true
+	}
+	// Keep configuration order so the table layout doesn't depend on the order health checks report in.
+	backends := make([]string, 0, len(healthyBackends))
+	for _, backend := range w.allBackends {
+		if healthySet[backend] {
+			backends = append(backends, backend)
+		}
+	}
+
+	w.rebuildMu.Lock()
+	defer w.rebuildMu.Unlock()
+	w.table.Store(w.buildTable(backends))
+}
+
+// buildTable runs Vose's alias method over the (slow-start scaled) weights of backends.
+func (w *WeightedRandom) buildTable(backends []string) *aliasTable {
+	n := len(backends)
+	table := &aliasTable{
+		backends: append([]string(nil), backends...),
+		prob:     make([]float64, n),
+		alias:    make([]int, n),
+		builtAt:  time.Now(),
+	}
+	if n == 0 {
+		return table
+	}
+
+	scaled := make([]float64, n)
+	total := 0.0
+	for i, backend := range backends {
+		factor := w.slowStart.Factor(backend)
+		if factor < 1 {
+			table.ramped = true
+		}
+		scaled[i] = w.weightOf[backend] * factor
+		total += scaled[i]
+	}
+
+	var small, large []int
+	for i := range scaled {
+		scaled[i] = scaled[i] * float64(n) / total
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small = small[:len(small)-1]
+		table.prob[s] = scaled[s]
+		table.alias[s] = l
+		scaled[l] = scaled[l] + scaled[s] - 1
+		if scaled[l] < 1 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+	// Whatever is left is 1 up to floating point error.
+	for _, i := range append(small, large...) {
+		table.prob[i] = 1
+	}
+	return table
+}
@@ -0,0 +1,129 @@
+package balancers
+
+import (
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewWeightedRandom_Config(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      map[string]any
+		expectError bool
+	}{
+		{name: "valid weights", config: map[string]any{"weights": []interface{}{3, 1}}},
+		{name: "missing weights", config: map[string]any{}, expectError: true},
+		{name: "mismatched weights", config: map[string]any{"weights": []interface{}{3}}, expectError: true},
+		{name: "non-int weight", config: map[string]any{"weights": []interface{}{3, "1"}}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWeightedRandom(&utils.Service{Backends: utils.BackendsFromURLs("A", "B"), StrategyConfig: tt.config})
+			if (err != nil) != tt.expectError {
+				t.Errorf("NewWeightedRandom() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestWeightedRandom_AliasTableProbabilities(t *testing.T) {
+	w, err := NewWeightedRandom(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B", "C", "D"),
+		StrategyConfig: map[string]any{"weights": []interface{}{5, 3, 1, 1}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := aliasProbabilities(w.table.Load())
+	want := map[string]float64{"A": 0.5, "B": 0.3, "C": 0.1, "D": 0.1}
+	for backend, p := range want {
+		if math.Abs(got[backend]-p) > 1e-9 {
+			t.Errorf("P(%s) = %v, want %v", backend, got[backend], p)
+		}
+	}
+}
+
+func TestWeightedRandom_SlowStartRestoresFullWeights(t *testing.T) {
+	w, err := NewWeightedRandom(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B"),
+		StrategyConfig: map[string]any{"weights": []interface{}{1, 1}},
+		SlowStart:      &utils.SlowStartConfig{DurationMs: 20},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w.SetHealthyBackends([]string{"A"})
+	w.SetHealthyBackends([]string{"A", "B"})
+	if p := aliasProbabilities(w.table.Load())["B"]; p >= 0.5 {
+		t.Fatalf("P(B) right after recovery = %v, want below 0.5", p)
+	}
+
+	// No health change after the ramp: the next pick alone has to bring back the full weights.
+	time.Sleep(50 * time.Millisecond)
+	if _, err := w.PickBackend(nil); err != nil {
+		t.Fatalf("PickBackend() unexpected error: %v", err)
+	}
+	if p := aliasProbabilities(w.table.Load())["B"]; math.Abs(p-0.5) > 1e-9 {
+		t.Errorf("P(B) after the ramp = %v, want 0.5", p)
+	}
+}
+
+func TestWeightedRandom_PickBackend(t *testing.T) {
+	w, err := NewWeightedRandom(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B"),
+		StrategyConfig: map[string]any{"weights": []interface{}{3, 1}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		got, err := w.PickBackend(nil)
+		if err != nil {
+			t.Fatalf("PickBackend() unexpected error: %v", err)
+		}
+		counts[got]++
+	}
+	if share := float64(counts["A"]) / 10000; share < 0.72 || share > 0.78 {
+		t.Errorf("A received %.3f of picks, want ~0.75 (%v)", share, counts)
+	}
+}
+
+func TestWeightedRandom_SetHealthyBackends(t *testing.T) {
+	w, err := NewWeightedRandom(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B", "C"),
+		StrategyConfig: map[string]any{"weights": []interface{}{3, 1, 1}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w.SetHealthyBackends([]string{"C", "B"})
+	for i := 0; i < 100; i++ {
+		if got, _ := w.PickBackend(nil); got == "A" {
+			t.Fatalf("picked unhealthy backend A")
+		}
+	}
+
+	w.SetHealthyBackends(nil)
+	if _, err := w.PickBackend(nil); err == nil {
+		t.Errorf("expected error with no healthy backends")
+	}
+}
+
+// aliasProbabilities recovers the exact probability of each backend from the table.
+func aliasProbabilities(table *aliasTable) map[string]float64 {
+	n := float64(len(table.backends))
+	got := map[string]float64{}
+	for i, backend := range table.backends {
+		got[backend] += table.prob[i] / n
+		got[table.backends[table.alias[i]]] += (1 - table.prob[i]) / n
+	}
+	return got
+}
@@ -2,51 +2,110 @@ package balancers
 
 import (
 	"errors"
-	"fmt"
+	"github.com/aribhuiya/stormgate/internal/balancers/slowstart"
 	"github.com/aribhuiya/stormgate/internal/utils"
 	"net/http"
+	"sync"
 	"sync/atomic"
 )
 
+// maxScheduleLength caps the precomputed cycle. Weights summing to more than this (after dividing by their
+// gcd) are served by running the algorithm live instead, so a huge weight ratio can't blow up memory.
+const maxScheduleLength = 1 << 14
+
+// WeightedRoundRobin spreads requests using nginx's smooth weighted round robin, so weights [5,1,1] yield
+// A A B A C A A rather than five A's in a row. One cycle of the algorithm (sum of weights picks) returns every
+// current weight to zero, so the cycle is precomputed whenever the healthy set changes and picks stay a
+// single atomic increment. Picks fall back to running the algorithm live under a mutex while slow start is
+// ramping a backend, as the weights then change continuously, and when the cycle would exceed
+// maxScheduleLength.
 type WeightedRoundRobin struct {
 	counter     atomic.Uint64 // for counting the total requests. thread-safe at CPU level
 	service     *utils.Service
-	n           uint64
-	weights     []int32
-	totalWeight uint64
+	state       atomic.Pointer[wrrState]
 	allBackends []string
 	allWeights  []int32
+	slowStart   *slowstart.Tracker
+	mu          sync.Mutex
+	current     []float64 // live smooth-WRR state, only used off the schedule
+	currentFor  *wrrState // the snapshot current was built for
 }
 
-func validateWeights(backends []string, weights []int32) error {
-	if len(backends) != len(weights) {
-		return fmt.Errorf("number of weights (%d) does not match number of backends (%d)", len(weights), len(backends))
-	}
-
-	for i, w := range weights {
-		if w == 0 {
-			return fmt.Errorf("weight at index %d is zero — must be a positive integer", i)
-		}
-	}
-	return nil
+// wrrState is the healthy backend set with its weights, replaced as a whole on every health change.
+type wrrState struct {
+	backends []string
+	weights  []int32
+	schedule []string // one full smooth-WRR cycle over the healthy backends; nil when too long to precompute
 }
 
 func (w *WeightedRoundRobin) PickBackend(*http.Request) (string, error) {
-	if len(w.service.Backends) == 0 || len(w.weights) != len(w.service.Backends) {
-		return "", errors.New("no healthy backends")
+	state := w.state.Load()
+	if len(state.backends) == 0 {
+		return "", utils.ErrNoHealthyBackends
+	}
+	if state.schedule == nil || w.slowStart.Ramping() {
+		return w.pickLive(state), nil
 	}
 
-	index := (w.counter.Add(1) - 1) % w.totalWeight
+	index := (w.counter.Add(1) - 1) % uint64(len(state.schedule))
+	return state.schedule[index], nil
+}
 
-	accum := uint64(0)
-	for i, weight := range w.weights {
-		accum += uint64(weight)
-		if index < accum {
-			return w.service.Backends[i], nil
+// pickLive runs one step of smooth weighted round robin: each backend's current weight grows by its
+// effective weight, the largest wins and is pushed back by the total.
+func (w *WeightedRoundRobin) pickLive(state *wrrState) string {
+	backends, weights := state.backends, state.weights
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.currentFor != state {
+		w.current = make([]float64, len(backends))
+		w.currentFor = state
+	}
+
+	ramping := w.slowStart.Ramping()
+	total := 0.0
+	best := 0
+	for i, backend := range backends {
+		effective := float64(weights[i])
+		if ramping {
+			effective *= w.slowStart.Factor(backend)
+		}
+		w.current[i] += effective
+		total += effective
+		if w.current[i] > w.current[best] {
+			best = i
 		}
 	}
+	w.current[best] -= total
+	return backends[best]
+}
 
-	return "", errors.New("unreachable state")
+// smoothSchedule runs one cycle of smooth weighted round robin, or returns nil when the cycle would be longer
+// than maxScheduleLength.
+func smoothSchedule(backends []string, weights []int32) []string {
+	total := int64(0)
+	for _, weight := range weights {
+		total += int64(weight)
+	}
+	if total == 0 || total > maxScheduleLength {
+		return nil
+	}
+
+	current := make([]int64, len(weights))
+	schedule := make([]string, 0, total)
+	for k := int64(0); k < total; k++ {
+		best := 0
+		for i, weight := range weights {
+			current[i] += int64(weight)
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		schedule = append(schedule, backends[best])
+	}
+	return schedule
 }
 
 func normalizeWeights(weights []int32) []int32 {
@@ -74,54 +133,53 @@ func normalizeWeights(weights []int32) []int32 {
 }
 
 func NewWeightedRoundRobin(service *utils.Service) (*WeightedRoundRobin, error) {
-	if len(service.Backends) == 0 {
+	backends := service.BackendURLs()
+	if len(backends) == 0 {
 		err := errors.New("no available backends")
 		return nil, err
 	}
 
-	rawWeights, ok := service.StrategyConfig["weights"].([]interface{})
-	if !ok {
-		return nil, errors.New("weights missing or malformed")
+	weights, err := utils.ParseWeights(service)
+	if err != nil {
+		return nil, err
 	}
-	weights := make([]int32, len(rawWeights))
-	for i, w := range rawWeights {
-		intVal, ok := w.(int)
-		if !ok {
-			return nil, fmt.Errorf("weight at index %d is not an int", i)
-		}
-		weights[i] = int32(intVal)
+	if weights == nil {
+		return nil, errors.New("weights missing or malformed")
 	}
 
-	err := validateWeights(service.Backends, weights)
+	slowStart, err := slowstart.NewTracker(service)
 	if err != nil {
 		return nil, err
 	}
 
 	normalized := normalizeWeights(weights)
-	totalWeight := uint64(0)
-	for _, weight := range normalized {
-		totalWeight += uint64(weight)
-	}
 
-	return &WeightedRoundRobin{
-		counter:     atomic.Uint64{},
+	w := &WeightedRoundRobin{
 		service:     service,
-		n:           uint64(len(service.Backends)),
-		weights:     normalized,
-		totalWeight: totalWeight,
-		allBackends: append([]string(nil), service.Backends...),
-		allWeights:  append([]int32(nil), normalized...),
-	}, nil
+		allBackends: backends,
+		allWeights:  normalized,
+		slowStart:   slowStart,
+	}
+	w.state.Store(newWRRState(backends, normalized))
+	return w, nil
+}
+
+func newWRRState(backends []string, weights []int32) *wrrState {
+	return &wrrState{
+		backends: backends,
+		weights:  weights,
+		schedule: smoothSchedule(backends, weights),
+	}
 }
 
 func (w *WeightedRoundRobin) SetHealthyBackends(healthyBackends []string) {
-	if hasChanged := utils.HasBackendChanged(w.service.Backends, healthyBackends); !hasChanged {
+	if hasChanged := utils.HasBackendChanged(w.state.Load().backends, healthyBackends); !hasChanged {
 		return
 	}
+	w.slowStart.Update(healthyBackends)
+
 	if len(healthyBackends) == 0 {
-		w.service.Backends = nil
-		w.weights = nil
-		w.totalWeight = 0
+		w.state.Store(&wrrState{})
 		return
 	}
 
@@ -139,14 +197,10 @@ func (w *WeightedRoundRobin) SetHealthyBackends(healthyBackends []string) {
 			newWeights = append(newWeights, w.allWeights[i])
 		}
 	}
-
-	norm := normalizeWeights(newWeights)
-	total := uint64(0)
-	for _, w := range norm {
-		total += uint64(w)
+	if len(newBackends) == 0 {
+		w.state.Store(&wrrState{})
+		return
 	}
 
-	w.service.Backends = newBackends
-	w.weights = norm
-	w.totalWeight = total
+	w.state.Store(newWRRState(newBackends, normalizeWeights(newWeights)))
 }
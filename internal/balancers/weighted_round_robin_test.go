@@ -1,7 +1,9 @@
 package balancers
 
 import (
+	"fmt"
 	"github.com/aribhuiya/stormgate/internal/utils"
+	"strings"
 	"testing"
 )
 
@@ -10,7 +12,6 @@ func TestWeightedRoundRobin_PickBackend(t *testing.T) {
 		name        string
 		backends    []string
 		weights     []int32
-		totalWeight uint64
 		expectedSeq []string // Expected output for successive calls
 		expectErr   bool
 	}{
@@ -18,48 +19,126 @@ func TestWeightedRoundRobin_PickBackend(t *testing.T) {
 			name:        "single backend",
 			backends:    []string{"A"},
 			weights:     []int32{1},
-			totalWeight: 1,
 			expectedSeq: []string{"A", "A", "A"},
 		},
 		{
 			name:        "two backends equal weight",
 			backends:    []string{"A", "B"},
 			weights:     []int32{1, 1},
-			totalWeight: 2,
 			expectedSeq: []string{"A", "B", "A", "B"},
 		},
 		{
 			name:        "three backends with varying weights",
 			backends:    []string{"A", "B", "C"},
 			weights:     []int32{3, 1, 2},
-			totalWeight: 6,
-			expectedSeq: []string{"A", "A", "A", "B", "C", "C", "A"}, // wrapping around
+			expectedSeq: []string{"A", "C", "A", "B", "C", "A", "A"}, // interleaved, wrapping around
 		},
 		{
-			name:        "no backends",
-			backends:    []string{},
-			weights:     []int32{},
-			totalWeight: 0,
-			expectErr:   true,
+			name:        "heavy backend is interleaved with light ones",
+			backends:    []string{"A", "B", "C"},
+			weights:     []int32{5, 1, 1},
+			expectedSeq: []string{"A", "A", "B", "A", "C", "A", "A", "A", "A", "B"},
+		},
+		{
+			name:        "large weight ratio",
+			backends:    []string{"A", "B"},
+			weights:     []int32{20000000, 1},
+			expectedSeq: []string{"A", "A", "A", "A"},
+		},
+		{
+			name:      "no backends",
+			backends:  []string{},
+			weights:   []int32{},
+			expectErr: true,
 		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			w := &WeightedRoundRobin{
-				service:     &utils.Service{Backends: tt.backends},
-				weights:     tt.weights,
-				totalWeight: tt.totalWeight,
-			}
-			for i, want := range tt.expectedSeq {
-				got, err := w.PickBackend(nil)
-				if (err != nil) != tt.expectErr {
-					t.Fatalf("PickBackend() error = %v, wantErr %v", err, tt.expectErr)
+	// Both the precomputed schedule and the live algorithm must produce the same interleaved sequence.
+	for _, live := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/live=%v", tt.name, live), func(t *testing.T) {
+				w := &WeightedRoundRobin{}
+				state := newWRRState(tt.backends, tt.weights)
+				if live {
+					state.schedule = nil
 				}
-				if err == nil && got != want {
-					t.Errorf("PickBackend() call %d = %v, want %v", i+1, got, want)
+				w.state.Store(state)
+				for i, want := range tt.expectedSeq {
+					got, err := w.PickBackend(nil)
+					if (err != nil) != tt.expectErr {
+						t.Fatalf("PickBackend() error = %v, wantErr %v", err, tt.expectErr)
+					}
+					if err == nil && got != want {
+						t.Errorf("PickBackend() call %d = %v, want %v", i+1, got, want)
+					}
 				}
-			}
-		})
+			})
+		}
+	}
+}
+
+func TestSmoothSchedule(t *testing.T) {
+	schedule := smoothSchedule([]string{"A", "B", "C"}, []int32{5, 1, 1})
+	if got := strings.Join(schedule, " "); got != "A A B A C A A" {
+		t.Errorf("smoothSchedule([5,1,1]) = %v, want A A B A C A A", got)
+	}
+	if schedule := smoothSchedule([]string{"A", "B"}, []int32{20000000, 1}); schedule != nil {
+		t.Errorf("smoothSchedule() precomputed %d entries, want nil above maxScheduleLength", len(schedule))
+	}
+}
+
+func TestWeightedRoundRobin_SetHealthyBackends(t *testing.T) {
+	w, err := NewWeightedRoundRobin(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B", "C"),
+		StrategyConfig: map[string]any{"weights": []interface{}{2, 1, 1}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w.SetHealthyBackends([]string{"A", "C"})
+	counts := map[string]int{}
+	for i := 0; i < 30; i++ {
+		got, err := w.PickBackend(nil)
+		if err != nil {
+			t.Fatalf("PickBackend() unexpected error: %v", err)
+		}
+		counts[got]++
+	}
+	if counts["B"] != 0 || counts["A"] != 20 || counts["C"] != 10 {
+		t.Errorf("unexpected distribution over healthy backends: %v", counts)
+	}
+
+	w.SetHealthyBackends([]string{})
+	if _, err := w.PickBackend(nil); err == nil {
+		t.Errorf("expected error with no healthy backends")
+	}
+}
+
+func TestWeightedRoundRobin_SlowStart(t *testing.T) {
+	minWeightPercent := 10.0
+	w, err := NewWeightedRoundRobin(&utils.Service{
+		Backends:       utils.BackendsFromURLs("A", "B"),
+		StrategyConfig: map[string]any{"weights": []interface{}{1, 1}},
+		SlowStart:      &utils.SlowStartConfig{DurationMs: 3600000, MinWeightPercent: &minWeightPercent},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w.SetHealthyBackends([]string{"A"})
+	w.SetHealthyBackends([]string{"A", "B"})
+
+	// B has just recovered and sits at the 10% floor of an hour-long ramp.
+	counts := map[string]int{}
+	for i := 0; i < 110; i++ {
+		got, err := w.PickBackend(nil)
+		if err != nil {
+			t.Fatalf("PickBackend() unexpected error: %v", err)
+		}
+		counts[got]++
+	}
+	if counts["B"] < 9 || counts["B"] > 11 {
+		t.Errorf("recovering backend received %d of 110 picks, want ~10 (%v)", counts["B"], counts)
 	}
 }
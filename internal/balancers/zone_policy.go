@@ -0,0 +1,101 @@
+package balancers
+
+import (
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"log"
+	"sync/atomic"
+)
+
+// zonePolicy keeps traffic in Stormgate's own zone and spills over to every zone only while the healthy
+// fraction of the local backends is below the threshold (or none of them are healthy).
+type zonePolicy struct {
+	serviceName string
+	zone        string
+	local       map[string]bool
+	threshold   float64
+	spilling    atomic.Bool
+	healthyFrac atomic.Pointer[float64]
+}
+
+// NewZonePolicy returns nil when no backend has a zone, Stormgate's zone is unknown or the service has no
+// backends in it.
+func NewZonePolicy(service *utils.Service, localZone string) (Policy, error) {
+	known := make(map[string]bool)
+	for _, b := range service.AllBackends() {
+		known[b.URL] = true
+	}
+	for backend := range service.BackendZones {
+		if !known[backend] {
+			return nil, fmt.Errorf("backend_zones references unknown backend %s", backend)
+		}
+	}
+
+	local := make(map[string]bool)
+	zoned := false
+	for _, b := range service.AllBackends() {
+		if b.Zone == "" {
+			continue
+		}
+		zoned = true
+		if b.Zone == localZone {
+			local[b.URL] = true
+		}
+	}
+	if !zoned {
+		return nil, nil
+	}
+	if localZone == "" {
+		log.Printf("Service %s: backend zones set but server zone is empty, zone-aware routing disabled", service.Name)
+		return nil, nil
+	}
+	if service.LocalZoneThreshold < 0 || service.LocalZoneThreshold > 1 {
+		return nil, fmt.Errorf("local_zone_threshold %v must be between 0 and 1", service.LocalZoneThreshold)
+	}
+	if len(local) == 0 {
+		log.Printf("Service %s: no backends in zone %s, zone-aware routing disabled", service.Name, localZone)
+		return nil, nil
+	}
+
+	z := &zonePolicy{
+		serviceName: service.Name,
+		zone:        localZone,
+		local:       local,
+		threshold:   service.LocalZoneThreshold,
+	}
+	full := 1.0
+	z.healthyFrac.Store(&full)
+	return z, nil
+}
+
+func (z *zonePolicy) Apply(eligible, healthy []string) ([]string, []string) {
+	localEligible := intersect(eligible, z.local)
+	if len(localEligible) == 0 {
+		// The current tier has no local backends; nothing to prefer.
+		return eligible, healthy
+	}
+	localHealthy := intersect(healthy, z.local)
+	fraction := float64(len(localHealthy)) / float64(len(localEligible))
+	z.healthyFrac.Store(&fraction)
+
+	spill := len(localHealthy) == 0 || fraction < z.threshold
+	if z.spilling.Swap(spill) != spill {
+		if spill {
+			log.Printf("Service %s: %d/%d backends healthy in zone %s, spilling over to other zones",
+				z.serviceName, len(localHealthy), len(localEligible), z.zone)
+		} else {
+			log.Printf("Service %s: %d/%d backends healthy in zone %s, routing locally again",
+				z.serviceName, len(localHealthy), len(localEligible), z.zone)
+		}
+	}
+	if spill {
+		return eligible, healthy
+	}
+	return localEligible, localHealthy
+}
+
+func (z *zonePolicy) Status(status map[string]any) {
+	status["zone"] = z.zone
+	status["zone_spillover"] = z.spilling.Load()
+	status["local_healthy_fraction"] = *z.healthyFrac.Load()
+}
@@ -0,0 +1,133 @@
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Resolver finds the address of the client that sent a request. Forwarding headers are only believed when the
+// peer is a trusted proxy; the X-Forwarded-For / Forwarded chain is then walked from the right, skipping trusted
+// hops, and the first untrusted address is the client. Anything to its left was supplied by the client and can
+// be spoofed. A nil *Resolver trusts no proxy and always returns the peer address.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver parses the trusted_proxies list; entries are CIDRs or single addresses.
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, entry := range trustedProxies {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return nil, fmt.Errorf("trusted_proxies: %q is not a CIDR or IP address", entry)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+func (r *Resolver) isTrusted(addr netip.Addr) bool {
+	if r == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client address of req, or the raw RemoteAddr when it can't be parsed.
+func (r *Resolver) Resolve(req *http.Request) string {
+	peer, ok := parseHop(req.RemoteAddr)
+	if !ok {
+		return req.RemoteAddr
+	}
+	if !r.isTrusted(peer) {
+		return peer.String()
+	}
+
+	hops := forwardedFor(req.Header)
+	if hops == nil {
+		if realIP, ok := parseHop(req.Header.Get("X-Real-IP")); ok {
+			return realIP.String()
+		}
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			// Obfuscated or malformed: the chain can't be followed past this point.
+			break
+		}
+		client = hop
+		if !r.isTrusted(hop) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// forwardedFor returns the hop list, nearest client first, from the Forwarded header (RFC 7239) or, when that
+// is absent, from X-Forwarded-For. Repeated headers are concatenated in order.
+func forwardedFor(header http.Header) []string {
+	var hops []string
+	if values := header.Values("Forwarded"); len(values) > 0 {
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+					if found && strings.EqualFold(key, "for") {
+						hops = append(hops, strings.Trim(val, `"`))
+					}
+				}
+			}
+		}
+		return hops
+	}
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// parseHop accepts an address with or without a port, IPv6 optionally in brackets.
+func parseHop(hop string) (netip.Addr, bool) {
+	hop = strings.TrimSpace(hop)
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(hop, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the resolved client address.
+func NewContext(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, contextKey{}, clientIP)
+}
+
+// FromRequest returns the client address resolved for req by the gateway, falling back to the peer address
+// for requests that didn't pass through it.
+func FromRequest(req *http.Request) string {
+	if ip, ok := req.Context().Value(contextKey{}).(string); ok {
+		return ip
+	}
+	return (*Resolver)(nil).Resolve(req)
+}
@@ -0,0 +1,102 @@
+package clientip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	r, err := NewResolver([]string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:5555", want: "203.0.113.7"},
+		{
+			name:       "headers from untrusted peer are ignored",
+			remoteAddr: "203.0.113.7:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.1.1.1"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "single trusted hop",
+			remoteAddr: "10.1.2.3:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.4"}},
+			want:       "198.51.100.4",
+		},
+		{
+			name:       "spoofed entries left of the client are skipped",
+			remoteAddr: "10.1.2.3:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"6.6.6.6, 198.51.100.4, 192.168.1.1"}},
+			want:       "198.51.100.4",
+		},
+		{
+			name:       "repeated headers are one chain",
+			remoteAddr: "10.1.2.3:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"6.6.6.6", "198.51.100.4"}},
+			want:       "198.51.100.4",
+		},
+		{
+			name:       "all hops trusted yields the leftmost",
+			remoteAddr: "10.1.2.3:5555",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.9.9.9, 10.8.8.8"}},
+			want:       "10.9.9.9",
+		},
+		{
+			name:       "forwarded header wins over x-forwarded-for",
+			remoteAddr: "10.1.2.3:5555",
+			headers: map[string][]string{
+				"Forwarded":       {`for=6.6.6.6, for="[2001:db8:cafe::17]:4711";proto=https, for=198.51.100.4;by=10.0.0.1`},
+				"X-Forwarded-For": {"7.7.7.7"},
+			},
+			want: "198.51.100.4",
+		},
+		{
+			name:       "obfuscated hop stops the walk",
+			remoteAddr: "10.1.2.3:5555",
+			headers:    map[string][]string{"Forwarded": {"for=198.51.100.4, for=_hidden, for=10.0.0.5"}},
+			want:       "10.0.0.5",
+		},
+		{
+			name:       "x-real-ip from trusted peer",
+			remoteAddr: "[2001:db8::1]:5555",
+			headers:    map[string][]string{"X-Real-Ip": {"198.51.100.9"}},
+			want:       "198.51.100.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &http.Request{RemoteAddr: tt.remoteAddr, Header: http.Header(tt.headers)}
+			if got := r.Resolve(req); got != tt.want {
+				t.Errorf("Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewResolver_Invalid(t *testing.T) {
+	if _, err := NewResolver([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("expected error for invalid CIDR")
+	}
+	if _, err := NewResolver([]string{"proxy.internal"}); err == nil {
+		t.Errorf("expected error for hostname")
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	req := &http.Request{RemoteAddr: "203.0.113.7:5555", Header: http.Header{"X-Forwarded-For": {"1.1.1.1"}}}
+	if got := FromRequest(req); got != "203.0.113.7" {
+		t.Errorf("FromRequest() without context = %v, want peer address", got)
+	}
+	req = req.WithContext(NewContext(req.Context(), "198.51.100.4"))
+	if got := FromRequest(req); got != "198.51.100.4" {
+		t.Errorf("FromRequest() = %v, want resolved address", got)
+	}
+}
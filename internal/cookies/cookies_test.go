@@ -0,0 +1,124 @@
+package cookies
+
+import (
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	s := NewSigner([]byte("secret"))
+	signed := s.Sign("backend-1")
+
+	if value, ok := s.Verify(signed); !ok || value != "backend-1" {
+		t.Errorf("Verify(%q) = %q, %v, want backend-1, true", signed, value, ok)
+	}
+
+	tests := []struct {
+		name   string
+		signed string
+	}{
+		{name: "unsigned", signed: "backend-1"},
+		{name: "tampered value", signed: "backend-2" + signed[len("backend-1"):]},
+		{name: "other key", signed: NewSigner([]byte("other")).Sign("backend-1")},
+		{name: "empty", signed: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := s.Verify(tt.signed); ok {
+				t.Errorf("Verify(%q) accepted a bad signature", tt.signed)
+			}
+		})
+	}
+}
+
+func TestJar(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	Set(req, &http.Cookie{Name: "ignored", Value: "no jar"}) // must not panic
+
+	ctx, jar := NewContext(req.Context())
+	req = req.WithContext(ctx)
+	Set(req, &http.Cookie{Name: "a", Value: "1"})
+	Set(req, &http.Cookie{Name: "b", Value: "2"})
+	Set(req, &http.Cookie{Name: "a", Value: "3"})
+
+	rec := httptest.NewRecorder()
+	jar.Write(rec)
+	got := rec.Result().Cookies()
+	if len(got) != 2 || got[0].Name != "a" || got[0].Value != "3" || got[1].Name != "b" {
+		t.Errorf("Set-Cookie = %v, want a=3 and b=2", got)
+	}
+}
+
+func TestSigner_KeyRotation(t *testing.T) {
+	old := NewSigner([]byte("old"))
+	rotated := NewSigner([]byte("new"), []byte("old"))
+
+	if value, ok := rotated.Verify(old.Sign("v")); !ok || value != "v" {
+		t.Errorf("rotated signer rejected a value signed with the old key")
+	}
+	if _, ok := old.Verify(rotated.Sign("v")); ok {
+		t.Errorf("values must be signed with the first key")
+	}
+	if NewSigner() != nil {
+		t.Errorf("NewSigner() without keys should be nil")
+	}
+}
+
+func TestSpec(t *testing.T) {
+	spec, err := NewSpec(utils.CookieConfig{
+		Domain:   "example.com",
+		MaxAgeS:  60,
+		Secure:   true,
+		SameSite: "Strict",
+		Secrets:  []string{"k1"},
+	}, "default-name", "/app/", time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/app/", nil)
+	ctx, jar := NewContext(req.Context())
+	spec.Issue(req.WithContext(ctx), "abc")
+	set := jar.Cookies()
+	if len(set) != 1 {
+		t.Fatalf("expected one cookie, got %v", set)
+	}
+	c := set[0]
+	if c.Name != "default-name" || c.Path != "/app/" || c.Domain != "example.com" || c.MaxAge != 60 ||
+		!c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteStrictMode || c.Value == "abc" {
+		t.Errorf("Issue() = %+v, want configured attributes and a signed value", c)
+	}
+
+	req = httptest.NewRequest("GET", "/app/", nil)
+	req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	if value, ok := spec.Read(req); !ok || value != "abc" {
+		t.Errorf("Read() = %q, %v, want abc, true", value, ok)
+	}
+	req = httptest.NewRequest("GET", "/app/", nil)
+	req.AddCookie(&http.Cookie{Name: c.Name, Value: "abc"})
+	if _, ok := spec.Read(req); ok {
+		t.Errorf("Read() accepted an unsigned value")
+	}
+}
+
+func TestNewSpec_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  utils.CookieConfig
+	}{
+		{name: "unknown same_site", cfg: utils.CookieConfig{SameSite: "sometimes"}},
+		{name: "same_site none without secure", cfg: utils.CookieConfig{SameSite: "none"}},
+		{name: "negative max age", cfg: utils.CookieConfig{MaxAgeS: -1}},
+		{name: "empty secret", cfg: utils.CookieConfig{Secrets: []string{""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSpec(tt.cfg, "n", "/", 0); err == nil {
+				t.Errorf("NewSpec() expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package cookies
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// Jar collects the cookies that balancers want set on the response while they pick a backend. The gateway
+// attaches one to every request and writes it out before forwarding.
+type Jar struct {
+	mu      sync.Mutex
+	cookies []*http.Cookie
+}
+
+type jarKey struct{}
+
+// NewContext returns a copy of ctx carrying a new, empty jar.
+func NewContext(ctx context.Context) (context.Context, *Jar) {
+	jar := &Jar{}
+	return context.WithValue(ctx, jarKey{}, jar), jar
+}
+
+// Set queues cookie for the response to req, replacing a queued cookie of the same name. It is a no-op when
+// the request carries no jar.
+func Set(req *http.Request, cookie *http.Cookie) {
+	jar, ok := req.Context().Value(jarKey{}).(*Jar)
+	if !ok {
+		return
+	}
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+	for i, queued := range jar.cookies {
+		if queued.Name == cookie.Name {
+			jar.cookies[i] = cookie
+			return
+		}
+	}
+	jar.cookies = append(jar.cookies, cookie)
+}
+
+// Cookies returns the queued cookies.
+func (j *Jar) Cookies() []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]*http.Cookie(nil), j.cookies...)
+}
+
+// Write adds a Set-Cookie header for every queued cookie.
+func (j *Jar) Write(w http.ResponseWriter) {
+	for _, cookie := range j.Cookies() {
+		http.SetCookie(w, cookie)
+	}
+}
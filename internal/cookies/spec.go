@@ -0,0 +1,123 @@
+package cookies
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Spec describes a cookie Stormgate issues: its attributes and, optionally, the signer of its value.
+type Spec struct {
+	Name     string
+	Path     string
+	Domain   string
+	MaxAge   time.Duration // 0 is a session cookie
+	Secure   bool
+	SameSite http.SameSite
+	signer   *Signer // nil when values are not signed
+}
+
+// NewSpec builds a spec from cfg; empty fields take the given defaults.
+func NewSpec(cfg utils.CookieConfig, defaultName, defaultPath string, defaultMaxAge time.Duration) (*Spec, error) {
+	sameSite, err := ParseSameSite(cfg.SameSite)
+	if err != nil {
+		return nil, err
+	}
+	if sameSite == http.SameSiteNoneMode && !cfg.Secure {
+		return nil, errors.New("same_site none requires secure")
+	}
+	if cfg.MaxAgeS < 0 {
+		return nil, errors.New("max_age_s must not be negative")
+	}
+
+	spec := &Spec{
+		Name:     cfg.Name,
+		Path:     cfg.Path,
+		Domain:   cfg.Domain,
+		MaxAge:   time.Duration(cfg.MaxAgeS) * time.Second,
+		Secure:   cfg.Secure,
+		SameSite: sameSite,
+	}
+	if spec.Name == "" {
+		spec.Name = defaultName
+	}
+	if spec.Path == "" {
+		spec.Path = defaultPath
+	}
+	if cfg.MaxAgeS == 0 {
+		spec.MaxAge = defaultMaxAge
+	}
+
+	keys := make([][]byte, 0, len(cfg.Secrets))
+	for i, secret := range cfg.Secrets {
+		if secret == "" {
+			return nil, fmt.Errorf("secret at index %d is empty", i)
+		}
+		keys = append(keys, []byte(secret))
+	}
+	spec.signer = NewSigner(keys...)
+	return spec, nil
+}
+
+// WithSigner returns a copy of the spec that signs its values with signer.
+func (s *Spec) WithSigner(signer *Signer) *Spec {
+	c := *s
+	c.signer = signer
+	return &c
+}
+
+// Signed reports whether the spec signs its values.
+func (s *Spec) Signed() bool {
+	return s.signer != nil
+}
+
+// Issue queues the cookie with value for the response to req.
+func (s *Spec) Issue(req *http.Request, value string) {
+	if s.signer != nil {
+		value = s.signer.Sign(value)
+	}
+	cookie := &http.Cookie{
+		Name:     s.Name,
+		Value:    value,
+		Path:     s.Path,
+		Domain:   s.Domain,
+		Secure:   s.Secure,
+		HttpOnly: true,
+		SameSite: s.SameSite,
+	}
+	if s.MaxAge > 0 {
+		cookie.MaxAge = int(s.MaxAge / time.Second)
+		cookie.Expires = time.Now().Add(s.MaxAge)
+	}
+	Set(req, cookie)
+}
+
+// Read returns the value of the cookie on req. A signed spec only accepts values with a valid signature.
+func (s *Spec) Read(req *http.Request) (string, bool) {
+	cookie, err := req.Cookie(s.Name)
+	if err != nil {
+		return "", false
+	}
+	if s.signer == nil {
+		return cookie.Value, true
+	}
+	return s.signer.Verify(cookie.Value)
+}
+
+// ParseSameSite maps the same_site config value (lax, strict, none or empty for the browser default).
+func ParseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return http.SameSiteDefaultMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("unknown same_site %q — use lax, strict or none", value)
+}
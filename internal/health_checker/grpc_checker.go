@@ -0,0 +1,252 @@
+package health_checker
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/stormgate"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
+
+// Serving statuses of grpc.health.v1.HealthCheckResponse.
+const (
+	GRPC_STATUS_UNKNOWN         = 0
+	GRPC_STATUS_SERVING         = 1
+	GRPC_STATUS_NOT_SERVING     = 2
+	GRPC_STATUS_SERVICE_UNKNOWN = 3
+)
+
+var grpcStatusNames = map[uint64]string{
+	GRPC_STATUS_UNKNOWN:         "UNKNOWN",
+	GRPC_STATUS_SERVING:         "SERVING",
+	GRPC_STATUS_NOT_SERVING:     "NOT_SERVING",
+	GRPC_STATUS_SERVICE_UNKNOWN: "SERVICE_UNKNOWN",
+}
+
+// GrpcChecker calls grpc.health.v1.Health/Check on every backend and marks it healthy when the reply is
+// SERVING. It speaks HTTP/2 directly: cleartext (h2c) for http:// backends and TLS for https:// ones. The
+// request and response messages are small enough to encode by hand, so no gRPC library is needed.
+type GrpcChecker struct {
+	IntervalMs  uint64
+	Service     stormgate.Service
+	states      *healthStates
+	pool        *probePool
+	client      *http.Client
+	timeout     time.Duration
+	port        int
+	request     []byte // length-prefixed HealthCheckRequest, identical for every probe
+	fingerprint string
+}
+
+func NewGrpcChecker(service stormgate.Service, cfg *utils.HealthConfig) (*GrpcChecker, error) {
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("port %d is out of range", cfg.Port)
+	}
+	states, err := newHealthStates(service.Config.Name, service.Config.BackendURLs(), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	transport := &http.Transport{
+		Protocols:       protocols,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: cfg.TLSSkipVerify},
+	}
+
+	g := &GrpcChecker{
+		IntervalMs: uint64(cfg.Frequency),
+		Service:    service,
+		states:     states,
+		client:     &http.Client{Transport: transport},
+		timeout:    probeTimeout(cfg),
+		port:       cfg.Port,
+		request:    grpcFrame(encodeHealthCheckRequest(cfg.GRPCService)),
+	}
+	g.fingerprint = fmt.Sprintf("grpc|%q|%t|%s|", cfg.GRPCService, cfg.TLSSkipVerify, g.timeout)
+	return g, nil
+}
+
+func (g *GrpcChecker) CheckHealth() []string {
+	g.pool.checkAll(g.Service.Config.BackendURLs(), g.states, g.timeout, g.GetInterval()/2,
+		func(backend string) string { return g.fingerprint + g.target(backend) },
+		func(ctx context.Context, backend string) error { return g.probe(ctx, g.target(backend)) })
+	return g.states.healthy()
+}
+
+// target is the URL of the Check method on backend, on the configured port if one is set.
+func (g *GrpcChecker) target(backend string) string {
+	u, err := url.Parse(backend)
+	if err != nil {
+		return backend
+	}
+	if addr, err := backendAddr(backend, g.port); err == nil {
+		u.Host = addr
+	}
+	u.Path = grpcHealthCheckPath
+	u.RawQuery = ""
+	return u.String()
+}
+
+func (g *GrpcChecker) probe(ctx context.Context, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(g.request))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)
+	}
+	// A trailers-only response carries the gRPC status in the headers.
+	if err := grpcStatusError(resp.Header); err != nil {
+		return err
+	}
+	// Trailers are only available once the body is drained, and they explain a missing message.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxExpectRead))
+	if err != nil {
+		return err
+	}
+	if err := grpcStatusError(resp.Trailer); err != nil {
+		return err
+	}
+	message, err := readGrpcFrame(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	status, err := decodeHealthCheckResponse(message)
+	if err != nil {
+		return err
+	}
+	if status != GRPC_STATUS_SERVING {
+		name, ok := grpcStatusNames[status]
+		if !ok {
+			name = fmt.Sprintf("status %d", status)
+		}
+		return fmt.Errorf("service is %s", name)
+	}
+	return nil
+}
+
+// grpcStatusError returns the error carried by the grpc-status / grpc-message pair, if any.
+func grpcStatusError(h http.Header) error {
+	code := h.Get("Grpc-Status")
+	if code == "" || code == "0" {
+		return nil
+	}
+	if msg := h.Get("Grpc-Message"); msg != "" {
+		if unescaped, err := url.PathUnescape(msg); err == nil {
+			msg = unescaped
+		}
+		return fmt.Errorf("grpc status %s: %s", code, msg)
+	}
+	return fmt.Errorf("grpc status %s", code)
+}
+
+// grpcFrame prefixes message with the gRPC length-prefixed message header (uncompressed).
+func grpcFrame(message []byte) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+func readGrpcFrame(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("reading grpc message: %w", err)
+	}
+	if header[0] != 0 {
+		return nil, errors.New("compressed grpc messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > maxExpectRead {
+		return nil, fmt.Errorf("grpc message of %d bytes is too large", n)
+	}
+	message := make([]byte, n)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, fmt.Errorf("reading grpc message: %w", err)
+	}
+	return message, nil
+}
+
+// encodeHealthCheckRequest encodes HealthCheckRequest{service = 1}.
+func encodeHealthCheckRequest(service string) []byte {
+	if service == "" {
+		return nil
+	}
+	msg := []byte{1<<3 | 2} // field 1, length-delimited
+	msg = binary.AppendUvarint(msg, uint64(len(service)))
+	return append(msg, service...)
+}
+
+// decodeHealthCheckResponse reads the status (field 1) of a HealthCheckResponse, skipping unknown fields.
+func decodeHealthCheckResponse(msg []byte) (uint64, error) {
+	status := uint64(GRPC_STATUS_UNKNOWN)
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0, errors.New("malformed HealthCheckResponse")
+		}
+		msg = msg[n:]
+		field, wireType := tag>>3, tag&7
+		switch wireType {
+		case 0: // varint
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0, errors.New("malformed HealthCheckResponse")
+			}
+			msg = msg[n:]
+			if field == 1 {
+				status = v
+			}
+		case 1: // 64-bit
+			if len(msg) < 8 {
+				return 0, errors.New("malformed HealthCheckResponse")
+			}
+			msg = msg[8:]
+		case 2: // length-delimited
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < l {
+				return 0, errors.New("malformed HealthCheckResponse")
+			}
+			msg = msg[n+int(l):]
+		case 5: // 32-bit
+			if len(msg) < 4 {
+				return 0, errors.New("malformed HealthCheckResponse")
+			}
+			msg = msg[4:]
+		default:
+			return 0, fmt.Errorf("unsupported wire type %d in HealthCheckResponse", wireType)
+		}
+	}
+	return status, nil
+}
+
+func (g *GrpcChecker) CheckAndUpdateBalancer() {
+	healthyBackends := g.CheckHealth()
+	g.Service.Balancer.SetHealthyBackends(healthyBackends)
+}
+
+func (g *GrpcChecker) GetInterval() time.Duration {
+	return time.Duration(g.IntervalMs) * time.Millisecond
+}
@@ -0,0 +1,118 @@
+package health_checker
+
+import (
+	"encoding/binary"
+	"github.com/aribhuiya/stormgate/internal/stormgate"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// grpcHealthStub implements grpc.health.v1.Health/Check for the services in statuses; other services get
+// NOT_FOUND like a real server.
+func grpcHealthStub(t *testing.T, statuses map[string]uint64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.URL.Path != grpcHealthCheckPath || r.Header.Get("Content-Type") != "application/grpc" {
+			t.Errorf("unexpected request %s %s %s", r.Proto, r.URL.Path, r.Header.Get("Content-Type"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		message, err := readGrpcFrame(r.Body)
+		if err != nil {
+			t.Errorf("reading request: %v", err)
+			return
+		}
+		_, _ = io.Copy(io.Discard, r.Body)
+		service := ""
+		if len(message) > 0 {
+			l, n := binary.Uvarint(message[1:])
+			service = string(message[1+n : 1+n+int(l)])
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		status, ok := statuses[service]
+		if !ok {
+			w.WriteHeader(http.StatusOK)
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "unknown%20service")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(grpcFrame(binary.AppendUvarint([]byte{1 << 3}, status)))
+		w.Header().Set("Grpc-Status", "0")
+	})
+}
+
+func startGrpcStub(t *testing.T, tls bool, statuses map[string]uint64) string {
+	t.Helper()
+	server := httptest.NewUnstartedServer(grpcHealthStub(t, statuses))
+	if tls {
+		server.EnableHTTP2 = true
+		server.StartTLS()
+	} else {
+		server.Config.Protocols = new(http.Protocols)
+		server.Config.Protocols.SetUnencryptedHTTP2(true)
+		server.Start()
+	}
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestGrpcChecker_CheckHealth(t *testing.T) {
+	statuses := map[string]uint64{"": GRPC_STATUS_SERVING, "orders": GRPC_STATUS_SERVING, "billing": GRPC_STATUS_NOT_SERVING}
+	h2c := startGrpcStub(t, false, statuses)
+	h2 := startGrpcStub(t, true, statuses)
+	down := closedAddr(t)
+
+	tests := []struct {
+		name string
+		cfg  utils.HealthConfig
+		want []string
+	}{
+		{name: "whole server", cfg: utils.HealthConfig{TLSSkipVerify: true}, want: []string{h2c, h2}},
+		{name: "serving service", cfg: utils.HealthConfig{GRPCService: "orders", TLSSkipVerify: true}, want: []string{h2c, h2}},
+		{name: "not serving", cfg: utils.HealthConfig{GRPCService: "billing", TLSSkipVerify: true}, want: nil},
+		{name: "unknown service", cfg: utils.HealthConfig{GRPCService: "missing", TLSSkipVerify: true}, want: nil},
+		{name: "untrusted certificate", cfg: utils.HealthConfig{GRPCService: "orders"}, want: []string{h2c}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.TimeoutMs = 1000
+			svc := stormgate.Service{Config: utils.Service{Backends: utils.BackendsFromURLs(h2c, h2, down)}}
+			checker, err := NewGrpcChecker(svc, &tt.cfg)
+			if err != nil {
+				t.Fatalf("NewGrpcChecker() unexpected error: %v", err)
+			}
+			if got := checker.CheckHealth(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckHealth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeHealthCheckResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     []byte
+		want    uint64
+		wantErr bool
+	}{
+		{name: "empty is UNKNOWN", msg: nil, want: GRPC_STATUS_UNKNOWN},
+		{name: "serving", msg: []byte{0x08, 0x01}, want: GRPC_STATUS_SERVING},
+		{name: "unknown fields skipped", msg: []byte{0x12, 0x02, 'h', 'i', 0x08, 0x02, 0x1d, 0, 0, 0, 0}, want: GRPC_STATUS_NOT_SERVING},
+		{name: "truncated", msg: []byte{0x12, 0x05, 'h'}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeHealthCheckResponse(tt.msg)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("decodeHealthCheckResponse() = %v, %v, want %v (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
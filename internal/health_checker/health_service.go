@@ -4,17 +4,47 @@ import (
 	"context"
 	"fmt"
 	"github.com/aribhuiya/stormgate/internal/stormgate"
+	"github.com/aribhuiya/stormgate/internal/utils"
 	"strings"
 	"time"
 )
 
 type HealthCheckerService struct {
-	checkers []HealthChecker
-	cancel   context.CancelFunc
+	checkers      []HealthChecker
+	states        map[string]*healthStates // by service name
+	hooks         []Hook
+	webhook       *webhookHook
+	pool          *probePool
+	jitterPercent int
+	cancel        context.CancelFunc
 }
 
-func NewHealthCheckerService(services map[string]*stormgate.Service) *HealthCheckerService {
-	var checkers []HealthChecker
+func NewHealthCheckerService(services map[string]*stormgate.Service, cfg utils.HealthChecksConfig) *HealthCheckerService {
+	if cfg.Workers < 0 {
+		panic("Health config error: 'workers' must not be negative")
+	}
+	if cfg.JitterPercent > 100 {
+		panic("Health config error: 'jitter_percent' must be at most 100")
+	}
+	h := &HealthCheckerService{
+		states:        make(map[string]*healthStates),
+		pool:          newProbePool(cfg.Workers),
+		jitterPercent: cfg.JitterPercent,
+	}
+	if h.jitterPercent == 0 {
+		h.jitterPercent = DefaultJitterPercent
+	}
+	if cfg.Hooks.Log == nil || *cfg.Hooks.Log {
+		h.AddHook(logHook{})
+	}
+	if cfg.Hooks.WebhookURL != "" {
+		webhook, err := newWebhookHook(cfg.Hooks)
+		if err != nil {
+			panic(fmt.Sprintf("Health config error: %v", err))
+		}
+		h.webhook = webhook
+		h.AddHook(webhook)
+	}
 
 	for _, svc := range services {
 		if svc.Config.Health == nil {
@@ -22,42 +52,101 @@ func NewHealthCheckerService(services map[string]*stormgate.Service) *HealthChec
 		}
 
 		healthCfg := svc.Config.Health
+		if healthCfg.Frequency <= 0 {
+			panic(fmt.Sprintf("Health config error in service '%s': 'frequency' must be greater than 0", svc.Config.Name))
+		}
 
 		switch strings.ToLower(healthCfg.Type) {
 		case "http":
 			if healthCfg.Endpoint == "" {
 				panic(fmt.Sprintf("Health config error in service '%s': missing 'health-endpoint'", svc.Config.Name))
 			}
-			if healthCfg.Frequency <= 0 {
-				panic(fmt.Sprintf("Health config error in service '%s': 'frequency' must be greater than 0", svc.Config.Name))
+			httpChecker, err := NewHttpChecker(*svc, healthCfg)
+			if err != nil {
+				panic(fmt.Sprintf("Health config error in service '%s': %v", svc.Config.Name, err))
+			}
+			httpChecker.pool = h.pool
+			h.register(svc, httpChecker, httpChecker.states)
+		case "tcp":
+			tcpChecker, err := NewTcpChecker(*svc, healthCfg)
+			if err != nil {
+				panic(fmt.Sprintf("Health config error in service '%s': %v", svc.Config.Name, err))
 			}
-			httpChecker := NewHttpChecker(*svc, healthCfg.Endpoint, uint64(healthCfg.Frequency))
-			checkers = append(checkers, httpChecker)
+			tcpChecker.pool = h.pool
+			h.register(svc, tcpChecker, tcpChecker.states)
+		case "grpc":
+			grpcChecker, err := NewGrpcChecker(*svc, healthCfg)
+			if err != nil {
+				panic(fmt.Sprintf("Health config error in service '%s': %v", svc.Config.Name, err))
+			}
+			grpcChecker.pool = h.pool
+			h.register(svc, grpcChecker, grpcChecker.states)
 		default:
 			panic(fmt.Sprintf("Health config error in service '%s': unsupported health type '%s'", svc.Config.Name, healthCfg.Type))
 		}
 	}
 
-	return &HealthCheckerService{
-		checkers: checkers,
+	return h
+}
+
+func (h *HealthCheckerService) register(svc *stormgate.Service, checker HealthChecker, states *healthStates) {
+	states.notify = h.emit
+	h.states[svc.Config.Name] = states
+	if strings.EqualFold(svc.Config.Health.InitialState, STATE_UNHEALTHY) {
+		// Keep the backends out of rotation until they pass their first checks.
+		svc.Balancer.SetHealthyBackends(states.healthy())
 	}
+	h.checkers = append(h.checkers, checker)
+}
+
+// AddHook registers hook to be notified whenever a backend changes health state, after the configured log
+// and webhook hooks. It must be called before StartService.
+func (h *HealthCheckerService) AddHook(hook Hook) {
+	h.hooks = append(h.hooks, hook)
+}
+
+// OnTransition registers fn as a hook.
+func (h *HealthCheckerService) OnTransition(fn func(Transition)) {
+	h.AddHook(HookFunc(fn))
+}
+
+func (h *HealthCheckerService) emit(t Transition) {
+	for _, hook := range h.hooks {
+		hook.OnTransition(t)
+	}
+}
+
+// History returns the recent health results of every checked backend, by service name and backend, oldest
+// first.
+func (h *HealthCheckerService) History() map[string]map[string][]Result {
+	history := make(map[string]map[string][]Result, len(h.states))
+	for name, states := range h.states {
+		history[name] = states.history()
+	}
+	return history
 }
 
 func (h *HealthCheckerService) StartService() {
 	ctx, cancel := context.WithCancel(context.Background())
 	h.cancel = cancel
+	if h.webhook != nil {
+		go h.webhook.run(ctx)
+	}
 
 	for _, checker := range h.checkers {
 		go func(c HealthChecker) {
-			ticker := time.NewTicker(c.GetInterval())
-			defer ticker.Stop()
+			// Check right away, then wait a jittered interval after each check so a slow check never
+			// overlaps the next one.
+			timer := time.NewTimer(0)
+			defer timer.Stop()
 
 			for {
 				select {
 				case <-ctx.Done():
 					return
-				case <-ticker.C:
+				case <-timer.C:
 					c.CheckAndUpdateBalancer()
+					timer.Reset(jitter(c.GetInterval(), h.jitterPercent))
 				}
 			}
 		}(checker)
@@ -0,0 +1,127 @@
+package health_checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultWebhookTimeout = 2 * time.Second
+	webhookQueueSize      = 64 // transitions waiting to be posted before new ones are dropped
+)
+
+// Hook is notified whenever a backend changes health state. Hooks run on the health-check goroutines, so they
+// must return quickly; anything slow belongs on a goroutine of its own.
+type Hook interface {
+	OnTransition(t Transition)
+}
+
+// HookFunc adapts a plain function to Hook.
+type HookFunc func(Transition)
+
+func (f HookFunc) OnTransition(t Transition) {
+	f(t)
+}
+
+// logHook writes one log line per transition.
+type logHook struct{}
+
+func (logHook) OnTransition(t Transition) {
+	if t.Healthy {
+		log.Printf("Service %s: backend %s is healthy", t.Service, t.Backend)
+	} else {
+		log.Printf("Service %s: backend %s is unhealthy: %v", t.Service, t.Backend, t.Err)
+	}
+}
+
+// webhookHook POSTs every transition as JSON. Posting happens on its own goroutine; when the endpoint falls
+// behind, transitions beyond the queue are dropped and logged rather than stalling the health checks.
+type webhookHook struct {
+	url     string
+	client  *http.Client
+	pending chan webhookEvent
+}
+
+type webhookEvent struct {
+	Service string    `json:"service"`
+	Backend string    `json:"backend"`
+	State   string    `json:"state"`
+	Error   string    `json:"error,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+func newWebhookHook(cfg utils.HooksConfig) (*webhookHook, error) {
+	// LoadConfig validates too; configs built in code get the same checks here.
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	timeout := DefaultWebhookTimeout
+	if cfg.WebhookTimeoutMs > 0 {
+		timeout = time.Duration(cfg.WebhookTimeoutMs) * time.Millisecond
+	}
+	return &webhookHook{
+		url:     cfg.WebhookURL,
+		client:  &http.Client{Timeout: timeout},
+		pending: make(chan webhookEvent, webhookQueueSize),
+	}, nil
+}
+
+func (w *webhookHook) OnTransition(t Transition) {
+	event := webhookEvent{Service: t.Service, Backend: t.Backend, State: STATE_UNHEALTHY, At: t.At}
+	if t.Healthy {
+		event.State = STATE_HEALTHY
+	}
+	if t.Err != nil {
+		event.Error = t.Err.Error()
+	}
+	select {
+	case w.pending <- event:
+	default:
+		log.Printf("Health webhook: queue full, dropping %s transition of %s", event.State, event.Backend)
+	}
+}
+
+// run posts queued transitions until ctx is cancelled.
+func (w *webhookHook) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-w.pending:
+			if err := w.post(ctx, event); err != nil {
+				log.Printf("Health webhook: posting %s transition of %s: %v", event.State, event.Backend, err)
+			}
+		}
+	}
+}
+
+func (w *webhookHook) post(ctx context.Context, event webhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package health_checker
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/balancers"
+	"github.com/aribhuiya/stormgate/internal/stormgate"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookHook_PostsTransitions(t *testing.T) {
+	received := make(chan webhookEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhookEvent
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&event) != nil {
+			t.Errorf("unexpected webhook request %s", r.Method)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	hook, err := newWebhookHook(utils.HooksConfig{WebhookURL: server.URL})
+	if err != nil {
+		t.Fatalf("newWebhookHook() unexpected error: %v", err)
+	}
+	h := &HealthCheckerService{webhook: hook}
+	h.AddHook(hook)
+	h.StartService()
+	defer h.StopService()
+
+	at := time.Unix(1700000000, 0).UTC()
+	h.emit(Transition{Service: "svc", Backend: "http://a", Healthy: false, Err: errors.New("refused"), At: at})
+	select {
+	case event := <-received:
+		want := webhookEvent{Service: "svc", Backend: "http://a", State: STATE_UNHEALTHY, Error: "refused", At: at}
+		if event != want {
+			t.Errorf("webhook event = %+v, want %+v", event, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
+
+func TestNewWebhookHook_Validation(t *testing.T) {
+	for _, cfg := range []utils.HooksConfig{
+		{WebhookURL: "localhost:9000/hook"},
+		{WebhookURL: "ftp://localhost/hook"},
+		{WebhookURL: "http://localhost/hook", WebhookTimeoutMs: -1},
+	} {
+		if _, err := newWebhookHook(cfg); err == nil {
+			t.Errorf("newWebhookHook(%+v) expected error", cfg)
+		}
+	}
+}
+
+func TestHealthCheckerService_HooksAndHistory(t *testing.T) {
+	up := startTCPServer(t, "")
+	down := closedAddr(t)
+	cfg := utils.Service{
+		Name:     "svc",
+		Backends: utils.BackendsFromURLs(up, down),
+		Health:   &utils.HealthConfig{Type: "tcp", Frequency: 1000, TimeoutMs: 500},
+	}
+	balancer, err := balancers.NewRoundRobin(&cfg)
+	if err != nil {
+		t.Fatalf("NewRoundRobin() unexpected error: %v", err)
+	}
+	services := map[string]*stormgate.Service{"/": {Config: cfg, Balancer: balancer}}
+
+	disabled := false
+	h := NewHealthCheckerService(services, utils.HealthChecksConfig{Hooks: utils.HooksConfig{Log: &disabled}})
+	var transitions []Transition
+	h.OnTransition(func(tr Transition) { transitions = append(transitions, tr) })
+	h.checkers[0].CheckAndUpdateBalancer()
+
+	if len(transitions) != 1 || transitions[0].Backend != down || transitions[0].Healthy {
+		t.Errorf("transitions = %+v, want only %s going down", transitions, down)
+	}
+	history := h.History()["svc"]
+	if len(history[up]) != 1 || !history[up][0].Passed || len(history[down]) != 1 || history[down][0].Error == "" {
+		t.Errorf("History() = %+v, want one passed result for %s and one failure for %s", history, up, down)
+	}
+}
@@ -1,55 +1,224 @@
 package health_checker
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/aribhuiya/stormgate/internal/stormgate"
+	"github.com/aribhuiya/stormgate/internal/utils"
 	"io"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
+const DefaultMaxBodyBytes = 64 * 1024
+
 type HttpChecker struct {
-	IntervalMs uint64
-	EndPoint   string
-	Service    stormgate.Service
+	IntervalMs   uint64
+	EndPoint     string
+	Service      stormgate.Service
+	states       *healthStates
+	pool         *probePool
+	client       *http.Client
+	timeout      time.Duration
+	fingerprint  string // identifies the probe settings when sharing results with other services
+	method       string
+	headers      http.Header
+	host         string
+	statuses     []statusRange
+	bodyContains []byte
+	bodyRegex    *regexp.Regexp
+	jsonPath     []string
+	jsonValue    string
+	maxBodyBytes int64
 }
 
-func NewHttpChecker(service stormgate.Service, endPoint string, interval uint64) *HttpChecker {
-	return &HttpChecker{
-		interval,
-		endPoint,
-		service,
-	}
+type statusRange struct {
+	from, to int
 }
 
-func (h *HttpChecker) CheckHealth() []string {
-	backends := h.Service.Config.Backends
-	var healthyBackends []string
+func NewHttpChecker(service stormgate.Service, cfg *utils.HealthConfig) (*HttpChecker, error) {
+	statuses, err := parseStatusRanges(cfg.ExpectedStatus)
+	if err != nil {
+		return nil, err
+	}
+	states, err := newHealthStates(service.Config.Name, service.Config.BackendURLs(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	method := strings.ToUpper(cfg.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	headers := make(http.Header, len(cfg.Headers))
+	for k, v := range cfg.Headers {
+		headers.Set(k, v)
+	}
 
-	for _, backend := range backends {
-		endpoint := strings.TrimRight(backend, "/") + "/" + h.EndPoint
-		if isHealthy(endpoint) {
-			healthyBackends = append(healthyBackends, backend)
+	h := &HttpChecker{
+		IntervalMs:   uint64(cfg.Frequency),
+		EndPoint:     cfg.Endpoint,
+		Service:      service,
+		states:       states,
+		client:       &http.Client{},
+		timeout:      probeTimeout(cfg),
+		method:       method,
+		headers:      headers,
+		host:         cfg.Host,
+		statuses:     statuses,
+		bodyContains: []byte(cfg.BodyContains),
+		jsonValue:    cfg.JSONValue,
+		maxBodyBytes: cfg.MaxBodyBytes,
+	}
+	if h.maxBodyBytes == 0 {
+		h.maxBodyBytes = DefaultMaxBodyBytes
+	} else if h.maxBodyBytes < 0 {
+		return nil, errors.New("max_body_bytes must be positive")
+	}
+	if cfg.BodyRegex != "" {
+		h.bodyRegex, err = regexp.Compile(cfg.BodyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("body_regex: %w", err)
 		}
 	}
+	if cfg.JSONPath != "" {
+		h.jsonPath = parseJSONPath(cfg.JSONPath)
+	} else if cfg.JSONValue != "" {
+		return nil, errors.New("json_value requires json_path")
+	}
+	h.fingerprint = fmt.Sprintf("http|%s|%s|%v|%v|%q|%v|%q|%q|%d|%s|", h.method, h.host, h.headers, h.statuses,
+		h.bodyContains, h.bodyRegex, h.jsonPath, h.jsonValue, h.maxBodyBytes, h.timeout)
+	return h, nil
+}
 
-	return healthyBackends
+// parseStatusRanges reads codes ("204") and inclusive ranges ("200-299"); no entries means exactly 200.
+func parseStatusRanges(specs []string) ([]statusRange, error) {
+	if len(specs) == 0 {
+		return []statusRange{{http.StatusOK, http.StatusOK}}, nil
+	}
+	ranges := make([]statusRange, 0, len(specs))
+	for _, spec := range specs {
+		fromText, toText, isRange := strings.Cut(strings.TrimSpace(spec), "-")
+		from, err := strconv.Atoi(strings.TrimSpace(fromText))
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(strings.TrimSpace(toText))
+		}
+		if err != nil || from < 100 || to > 599 || from > to {
+			return nil, fmt.Errorf("invalid expected_status %q", spec)
+		}
+		ranges = append(ranges, statusRange{from, to})
+	}
+	return ranges, nil
+}
+
+// parseJSONPath splits "$.checks[0].status" or "checks.0.status" into its keys.
+func parseJSONPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	return strings.Split(path, ".")
 }
 
-func isHealthy(url string) bool {
-	resp, err := http.Get(url)
+func (h *HttpChecker) CheckHealth() []string {
+	h.pool.checkAll(h.Service.Config.BackendURLs(), h.states, h.timeout, h.GetInterval()/2,
+		func(backend string) string { return h.fingerprint + h.endpoint(backend) },
+		func(ctx context.Context, backend string) error { return h.probe(ctx, h.endpoint(backend)) })
+	return h.states.healthy()
+}
+
+func (h *HttpChecker) endpoint(backend string) string {
+	return strings.TrimRight(backend, "/") + "/" + h.EndPoint
+}
+
+func (h *HttpChecker) probe(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, h.method, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range h.headers {
+		req.Header[k] = v
+	}
+	if h.host != "" {
+		req.Host = h.host
+	}
+
+	resp, err := h.client.Do(req)
 	if err != nil {
-		return false
+		return err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
-	if resp.StatusCode == http.StatusOK {
-		return true
+
+	if !h.statusAccepted(resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if len(h.bodyContains) == 0 && h.bodyRegex == nil && h.jsonPath == nil {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, h.maxBodyBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > h.maxBodyBytes {
+		return fmt.Errorf("response body exceeds %d bytes", h.maxBodyBytes)
+	}
+	return h.checkBody(body)
+}
+
+func (h *HttpChecker) statusAccepted(code int) bool {
+	for _, r := range h.statuses {
+		if code >= r.from && code <= r.to {
+			return true
+		}
 	}
 	return false
 }
 
+func (h *HttpChecker) checkBody(body []byte) error {
+	if len(h.bodyContains) > 0 && !bytes.Contains(body, h.bodyContains) {
+		return fmt.Errorf("body does not contain %q", h.bodyContains)
+	}
+	if h.bodyRegex != nil && !h.bodyRegex.Match(body) {
+		return fmt.Errorf("body does not match %s", h.bodyRegex)
+	}
+	if h.jsonPath == nil {
+		return nil
+	}
+
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("body is not JSON: %w", err)
+	}
+	for _, key := range h.jsonPath {
+		switch node := doc.(type) {
+		case map[string]any:
+			doc = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return fmt.Errorf("json_path index %q out of range", key)
+			}
+			doc = node[i]
+		default:
+			doc = nil
+		}
+		if doc == nil {
+			return fmt.Errorf("json_path %s not found", strings.Join(h.jsonPath, "."))
+		}
+	}
+	if h.jsonValue != "" && fmt.Sprintf("%v", doc) != h.jsonValue {
+		return fmt.Errorf("json_path %s is %v, want %s", strings.Join(h.jsonPath, "."), doc, h.jsonValue)
+	}
+	return nil
+}
+
 func (h *HttpChecker) CheckAndUpdateBalancer() {
 	healthyBackends := h.CheckHealth()
 	h.Service.Balancer.SetHealthyBackends(healthyBackends)
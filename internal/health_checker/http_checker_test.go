@@ -0,0 +1,100 @@
+package health_checker
+
+import (
+	"context"
+	"github.com/aribhuiya/stormgate/internal/stormgate"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHttpChecker(t *testing.T, cfg utils.HealthConfig) *HttpChecker {
+	t.Helper()
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "health"
+	}
+	checker, err := NewHttpChecker(stormgate.Service{}, &cfg)
+	if err != nil {
+		t.Fatalf("NewHttpChecker() unexpected error: %v", err)
+	}
+	return checker
+}
+
+func TestHttpChecker_probe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte(`{"status":"ok","checks":[{"name":"db","up":true}]}`))
+		case "/degraded":
+			_, _ = w.Write([]byte(`{"status":"degraded"}`))
+		case "/nocontent":
+			w.WriteHeader(http.StatusNoContent)
+		case "/large":
+			_, _ = w.Write([]byte(strings.Repeat("x", 100) + "ok"))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/echo":
+			if r.Method == http.MethodHead && r.Host == "health.internal" && r.Header.Get("X-Probe") == "1" {
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		cfg     utils.HealthConfig
+		healthy bool
+	}{
+		{name: "plain 200", path: "/ok", healthy: true},
+		{name: "204 rejected by default", path: "/nocontent", healthy: false},
+		{name: "204 in accepted range", path: "/nocontent", cfg: utils.HealthConfig{ExpectedStatus: []string{"200-299"}}, healthy: true},
+		{name: "body contains", path: "/ok", cfg: utils.HealthConfig{BodyContains: `"ok"`}, healthy: true},
+		{name: "body missing substring", path: "/degraded", cfg: utils.HealthConfig{BodyContains: `"ok"`}, healthy: false},
+		{name: "body regex", path: "/degraded", cfg: utils.HealthConfig{BodyRegex: `"status":\s*"(ok|degraded)"`}, healthy: true},
+		{name: "json path equals", path: "/ok", cfg: utils.HealthConfig{JSONPath: "status", JSONValue: "ok"}, healthy: true},
+		{name: "json path degraded", path: "/degraded", cfg: utils.HealthConfig{JSONPath: "$.status", JSONValue: "ok"}, healthy: false},
+		{name: "json path into array", path: "/ok", cfg: utils.HealthConfig{JSONPath: "$.checks[0].up", JSONValue: "true"}, healthy: true},
+		{name: "json path missing", path: "/degraded", cfg: utils.HealthConfig{JSONPath: "checks.0.up"}, healthy: false},
+		{name: "body over size cap", path: "/large", cfg: utils.HealthConfig{BodyContains: "ok", MaxBodyBytes: 50}, healthy: false},
+		{name: "timeout", path: "/slow", cfg: utils.HealthConfig{TimeoutMs: 50}, healthy: false},
+		{
+			name:    "method, host and headers",
+			path:    "/echo",
+			cfg:     utils.HealthConfig{Method: "head", Host: "health.internal", Headers: map[string]string{"X-Probe": "1"}},
+			healthy: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := newTestHttpChecker(t, tt.cfg)
+			ctx, cancel := context.WithTimeout(context.Background(), checker.timeout)
+			defer cancel()
+			err := checker.probe(ctx, server.URL+tt.path)
+			if (err == nil) != tt.healthy {
+				t.Errorf("probe() error = %v, want healthy %v", err, tt.healthy)
+			}
+		})
+	}
+}
+
+func TestNewHttpChecker_Validation(t *testing.T) {
+	for _, cfg := range []utils.HealthConfig{
+		{ExpectedStatus: []string{"2xx"}},
+		{ExpectedStatus: []string{"299-200"}},
+		{ExpectedStatus: []string{"700"}},
+		{BodyRegex: "("},
+		{JSONValue: "ok"},
+		{MaxBodyBytes: -1},
+	} {
+		if _, err := NewHttpChecker(stormgate.Service{}, &cfg); err == nil {
+			t.Errorf("NewHttpChecker(%+v) expected error", cfg)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package health_checker
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultProbeWorkers  = 32
+	DefaultJitterPercent = 10
+)
+
+// probePool runs the probes of every health checker on a bounded number of workers. Probes with the same key
+// (same target, same settings) are shared between services: a caller joins an identical probe that is still
+// running, or reuses its result if it finished recently enough.
+//
+// A nil *probePool runs every probe on its own goroutine without sharing results.
+type probePool struct {
+	workers chan struct{}
+
+	mu     sync.Mutex
+	probes map[string]*sharedProbe
+}
+
+type sharedProbe struct {
+	done   chan struct{} // closed once result is set
+	result probeResult
+}
+
+func newProbePool(workers int) *probePool {
+	if workers <= 0 {
+		workers = DefaultProbeWorkers
+	}
+	return &probePool{
+		workers: make(chan struct{}, workers),
+		probes:  make(map[string]*sharedProbe),
+	}
+}
+
+// checkAll probes every backend concurrently, each under its own timeout, and records the results in states
+// once all of them are in. key identifies a backend's probe for sharing; results younger than maxAge are reused.
+func (p *probePool) checkAll(backends []string, states *healthStates, timeout, maxAge time.Duration,
+	key func(backend string) string, probe func(ctx context.Context, backend string) error) {
+	results := make([]probeResult, len(backends))
+	var wg sync.WaitGroup
+	for i, backend := range backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = p.do(key(backend), maxAge, func() error {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				return probe(ctx, backend)
+			})
+		}()
+	}
+	wg.Wait()
+
+	for i, backend := range backends {
+		states.record(backend, results[i])
+	}
+}
+
+func (p *probePool) do(key string, maxAge time.Duration, probe func() error) probeResult {
+	if p == nil {
+		return timed(probe)
+	}
+
+	p.mu.Lock()
+	if shared, ok := p.probes[key]; ok {
+		select {
+		case <-shared.done:
+			if time.Since(shared.result.at) < maxAge {
+				p.mu.Unlock()
+				return shared.result
+			}
+		default:
+			p.mu.Unlock()
+			<-shared.done
+			return shared.result
+		}
+	}
+	shared := &sharedProbe{done: make(chan struct{})}
+	p.probes[key] = shared
+	p.mu.Unlock()
+
+	p.workers <- struct{}{}
+	shared.result = timed(probe)
+	<-p.workers
+
+	close(shared.done)
+	return shared.result
+}
+
+// timed runs probe and records when it finished and how long it took.
+func timed(probe func() error) probeResult {
+	start := time.Now()
+	err := probe()
+	at := time.Now()
+	return probeResult{err: err, latency: at.Sub(start), at: at}
+}
+
+// jitter spreads interval by up to ±percent so checkers started together don't keep probing in lockstep.
+func jitter(interval time.Duration, percent int) time.Duration {
+	if percent <= 0 {
+		return interval
+	}
+	spread := int64(interval) * int64(percent) / 100
+	if spread <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int63n(2*spread+1)-spread)
+}
@@ -0,0 +1,115 @@
+package health_checker
+
+import (
+	"context"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestStates(t *testing.T, backends ...string) *healthStates {
+	t.Helper()
+	states, err := newHealthStates("svc", backends, &utils.HealthConfig{})
+	if err != nil {
+		t.Fatalf("newHealthStates() unexpected error: %v", err)
+	}
+	return states
+}
+
+func identity(backend string) string { return backend }
+
+func TestProbePool_BoundsConcurrency(t *testing.T) {
+	pool := newProbePool(2)
+	backends := []string{"A", "B", "C", "D", "E", "F"}
+	var running, peak atomic.Int32
+	pool.checkAll(backends, newTestStates(t, backends...), time.Second, 0, identity,
+		func(ctx context.Context, backend string) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrent probes = %d, want 2", got)
+	}
+}
+
+func TestProbePool_SlowBackendTimesOutAlone(t *testing.T) {
+	states := newTestStates(t, "fast", "hung", "also-fast")
+	start := time.Now()
+	newProbePool(4).checkAll([]string{"fast", "hung", "also-fast"}, states, 50*time.Millisecond, 0, identity,
+		func(ctx context.Context, backend string) error {
+			if backend == "hung" {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return nil
+		})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("checkAll took %v, want about one probe timeout", elapsed)
+	}
+	if got := states.healthy(); !reflect.DeepEqual(got, []string{"fast", "also-fast"}) {
+		t.Errorf("healthy() = %v, want the hung backend marked unhealthy", got)
+	}
+}
+
+func TestProbePool_SharesIdenticalProbes(t *testing.T) {
+	pool := newProbePool(4)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	probe := func() error {
+		calls.Add(1)
+		<-release
+		return nil
+	}
+
+	// Two services probing the same target at the same moment share one probe.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = pool.do("http|GET|http://a/health", time.Second, probe)
+		}()
+	}
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("probe ran %d times for concurrent identical checks, want 1", got)
+	}
+
+	// A recent result is reused, a stale one or a different key probes again.
+	_ = pool.do("http|GET|http://a/health", time.Second, probe)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("probe ran %d times, want the recent result reused", got)
+	}
+	_ = pool.do("http|GET|http://a/health", 0, probe)
+	_ = pool.do("http|GET|http://b/health", time.Second, probe)
+	if got := calls.Load(); got != 3 {
+		t.Errorf("probe ran %d times, want stale and different probes to run", got)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if got := jitter(time.Second, 10); got < 900*time.Millisecond || got > 1100*time.Millisecond {
+			t.Fatalf("jitter(1s, 10) = %v, want within ±10%%", got)
+		}
+	}
+	if got := jitter(time.Second, -1); got != time.Second {
+		t.Errorf("jitter(1s, -1) = %v, want jitter disabled", got)
+	}
+}
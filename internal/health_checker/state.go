@@ -0,0 +1,177 @@
+package health_checker
+
+import (
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	STATE_HEALTHY   = "healthy"
+	STATE_UNHEALTHY = "unhealthy"
+
+	DefaultHistorySize = 32
+)
+
+// Transition is emitted whenever a backend changes state.
+type Transition struct {
+	Service string
+	Backend string
+	Healthy bool
+	Err     error // the failure that made the backend unhealthy, nil when it became healthy
+	At      time.Time
+}
+
+// Result is one probe outcome as kept in a backend's history.
+type Result struct {
+	At        time.Time `json:"at"`
+	LatencyMs float64   `json:"latency_ms"`
+	Passed    bool      `json:"passed"`
+	State     string    `json:"state"` // the backend's state after this result
+	Error     string    `json:"error,omitempty"`
+}
+
+// probeResult is what the pool hands back for one probe.
+type probeResult struct {
+	err     error
+	latency time.Duration
+	at      time.Time
+}
+
+// healthStates runs a rise/fall state machine per backend: a backend only becomes unhealthy after
+// unhealthyThreshold consecutive failed probes and only recovers after healthyThreshold consecutive successes,
+// so a single lost probe doesn't flap it in and out of rotation. The last historySize results of every
+// backend are kept for the admin API.
+type healthStates struct {
+	serviceName        string
+	healthyThreshold   int
+	unhealthyThreshold int
+	historySize        int
+	order              []string
+	notify             func(Transition) // called for every transition
+
+	mu       sync.Mutex
+	backends map[string]*backendState
+}
+
+type backendState struct {
+	healthy bool
+	streak  int      // consecutive results contradicting the current state
+	history []Result // ring buffer of the latest results
+	next    int      // where the next result goes once history is full
+}
+
+func newHealthStates(serviceName string, backends []string, cfg *utils.HealthConfig) (*healthStates, error) {
+	if cfg.HealthyThreshold < 0 || cfg.UnhealthyThreshold < 0 {
+		return nil, fmt.Errorf("healthy_threshold and unhealthy_threshold must not be negative (0 means the default of 1)")
+	}
+	if cfg.HistorySize < 0 {
+		return nil, fmt.Errorf("history_size must not be negative")
+	}
+	initiallyHealthy := true
+	switch strings.ToLower(cfg.InitialState) {
+	case "", STATE_HEALTHY:
+	case STATE_UNHEALTHY:
+		initiallyHealthy = false
+	default:
+		return nil, fmt.Errorf("unknown initial_state %q — use healthy or unhealthy", cfg.InitialState)
+	}
+
+	s := &healthStates{
+		serviceName:        serviceName,
+		healthyThreshold:   max(cfg.HealthyThreshold, 1),
+		unhealthyThreshold: max(cfg.UnhealthyThreshold, 1),
+		historySize:        cfg.HistorySize,
+		order:              backends,
+		backends:           make(map[string]*backendState, len(backends)),
+	}
+	if s.historySize == 0 {
+		s.historySize = DefaultHistorySize
+	}
+	for _, b := range backends {
+		s.backends[b] = &backendState{healthy: initiallyHealthy}
+	}
+	return s, nil
+}
+
+// record feeds one probe result into the backend's state machine and history.
+func (s *healthStates) record(backend string, result probeResult) {
+	s.mu.Lock()
+	state, ok := s.backends[backend]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	passed := result.err == nil
+	transitioned := false
+	if passed == state.healthy {
+		state.streak = 0
+	} else {
+		state.streak++
+		threshold := s.unhealthyThreshold
+		if passed {
+			threshold = s.healthyThreshold
+		}
+		if state.streak >= threshold {
+			state.healthy = passed
+			state.streak = 0
+			transitioned = true
+		}
+	}
+	s.remember(state, result)
+	s.mu.Unlock()
+
+	if transitioned && s.notify != nil {
+		s.notify(Transition{Service: s.serviceName, Backend: backend, Healthy: passed, Err: result.err, At: result.at})
+	}
+}
+
+func (s *healthStates) remember(state *backendState, result probeResult) {
+	entry := Result{
+		At:        result.at,
+		LatencyMs: float64(result.latency.Microseconds()) / 1000,
+		Passed:    result.err == nil,
+		State:     STATE_UNHEALTHY,
+	}
+	if state.healthy {
+		entry.State = STATE_HEALTHY
+	}
+	if result.err != nil {
+		entry.Error = result.err.Error()
+	}
+	if len(state.history) < s.historySize {
+		state.history = append(state.history, entry)
+		return
+	}
+	state.history[state.next] = entry
+	state.next = (state.next + 1) % s.historySize
+}
+
+// history returns every backend's recent results, oldest first.
+func (s *healthStates) history() map[string][]Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string][]Result, len(s.backends))
+	for backend, state := range s.backends {
+		results := make([]Result, 0, len(state.history))
+		results = append(results, state.history[state.next:]...)
+		results = append(results, state.history[:state.next]...)
+		out[backend] = results
+	}
+	return out
+}
+
+// healthy returns the backends currently in the healthy state, in configuration order.
+func (s *healthStates) healthy() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var healthy []string
+	for _, b := range s.order {
+		if s.backends[b].healthy {
+			healthy = append(healthy, b)
+		}
+	}
+	return healthy
+}
@@ -0,0 +1,91 @@
+package health_checker
+
+import (
+	"errors"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHealthStates_Thresholds(t *testing.T) {
+	states, err := newHealthStates("svc", []string{"A", "B"}, &utils.HealthConfig{HealthyThreshold: 2, UnhealthyThreshold: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var transitions []Transition
+	states.notify = func(tr Transition) { transitions = append(transitions, tr) }
+	fail := errors.New("connection refused")
+
+	// Two failures, a success and two more failures: never three in a row.
+	for _, err := range []error{fail, fail, nil, fail, fail} {
+		states.record("A", probeResult{err: err})
+	}
+	if got := states.healthy(); !reflect.DeepEqual(got, []string{"A", "B"}) {
+		t.Fatalf("healthy() = %v, want A still healthy below the threshold", got)
+	}
+
+	states.record("A", probeResult{err: fail})
+	if got := states.healthy(); !reflect.DeepEqual(got, []string{"B"}) {
+		t.Fatalf("healthy() = %v, want A unhealthy after 3 consecutive failures", got)
+	}
+
+	states.record("A", probeResult{err: nil})
+	states.record("A", probeResult{err: fail})
+	states.record("A", probeResult{err: nil})
+	if got := states.healthy(); !reflect.DeepEqual(got, []string{"B"}) {
+		t.Fatalf("healthy() = %v, want A to need 2 consecutive successes", got)
+	}
+	states.record("A", probeResult{err: nil})
+	if got := states.healthy(); !reflect.DeepEqual(got, []string{"A", "B"}) {
+		t.Fatalf("healthy() = %v, want A back", got)
+	}
+
+	if len(transitions) != 2 || transitions[0].Healthy || transitions[0].Err != fail || !transitions[1].Healthy {
+		t.Errorf("transitions = %+v, want down (with the error) then up", transitions)
+	}
+	if transitions[0].Service != "svc" || transitions[0].Backend != "A" {
+		t.Errorf("transition = %+v, want service svc and backend A", transitions[0])
+	}
+}
+
+func TestHealthStates_InitialState(t *testing.T) {
+	states, err := newHealthStates("svc", []string{"A", "B"}, &utils.HealthConfig{InitialState: "unhealthy"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := states.healthy(); got != nil {
+		t.Fatalf("healthy() = %v, want none before the first checks", got)
+	}
+	states.record("B", probeResult{err: nil})
+	if got := states.healthy(); !reflect.DeepEqual(got, []string{"B"}) {
+		t.Errorf("healthy() = %v, want B after one passed check", got)
+	}
+
+	for _, cfg := range []utils.HealthConfig{{InitialState: "maybe"}, {HealthyThreshold: -1}} {
+		if _, err := newHealthStates("svc", nil, &cfg); err == nil {
+			t.Errorf("newHealthStates(%+v) expected error", cfg)
+		}
+	}
+}
+
+func TestHealthStates_History(t *testing.T) {
+	states, err := newHealthStates("svc", []string{"A"}, &utils.HealthConfig{HistorySize: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start := time.Unix(0, 0)
+	for i, err := range []error{nil, errors.New("refused"), nil, errors.New("timeout"), errors.New("reset")} {
+		states.record("A", probeResult{err: err, latency: time.Duration(i) * time.Millisecond, at: start.Add(time.Duration(i) * time.Second)})
+	}
+
+	got := states.history()["A"]
+	want := []Result{
+		{At: start.Add(2 * time.Second), LatencyMs: 2, Passed: true, State: STATE_HEALTHY},
+		{At: start.Add(3 * time.Second), LatencyMs: 3, Passed: false, State: STATE_UNHEALTHY, Error: "timeout"},
+		{At: start.Add(4 * time.Second), LatencyMs: 4, Passed: false, State: STATE_UNHEALTHY, Error: "reset"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("history() = %+v, want the last 3 results oldest first %+v", got, want)
+	}
+}
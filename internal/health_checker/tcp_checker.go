@@ -0,0 +1,172 @@
+package health_checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/aribhuiya/stormgate/internal/stormgate"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultProbeTimeout = 2 * time.Second
+	maxExpectRead       = 4096 // bytes read while looking for the expected reply
+)
+
+// TcpChecker marks a backend healthy when a TCP connection to it completes within the timeout. With send
+// and/or expect it also writes a payload and waits for a reply containing the expected bytes.
+type TcpChecker struct {
+	IntervalMs uint64
+	Service    stormgate.Service
+	states     *healthStates
+	pool       *probePool
+	timeout    time.Duration
+	port       int
+	send       []byte
+	expect     []byte
+}
+
+func NewTcpChecker(service stormgate.Service, cfg *utils.HealthConfig) (*TcpChecker, error) {
+	send, err := bytesOption("send", cfg.Send, cfg.SendHex)
+	if err != nil {
+		return nil, err
+	}
+	expect, err := bytesOption("expect", cfg.Expect, cfg.ExpectHex)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("port %d is out of range", cfg.Port)
+	}
+	states, err := newHealthStates(service.Config.Name, service.Config.BackendURLs(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &TcpChecker{
+		IntervalMs: uint64(cfg.Frequency),
+		Service:    service,
+		states:     states,
+		timeout:    probeTimeout(cfg),
+		port:       cfg.Port,
+		send:       send,
+		expect:     expect,
+	}, nil
+}
+
+// bytesOption reads a payload given either as text or hex-encoded, but not both.
+func bytesOption(name, text, hexText string) ([]byte, error) {
+	if text != "" && hexText != "" {
+		return nil, fmt.Errorf("set only one of %s and %s_hex", name, name)
+	}
+	if hexText != "" {
+		b, err := hex.DecodeString(hexText)
+		if err != nil {
+			return nil, fmt.Errorf("%s_hex: %w", name, err)
+		}
+		return b, nil
+	}
+	if text != "" {
+		return []byte(text), nil
+	}
+	return nil, nil
+}
+
+func probeTimeout(cfg *utils.HealthConfig) time.Duration {
+	if cfg.TimeoutMs > 0 {
+		return time.Duration(cfg.TimeoutMs) * time.Millisecond
+	}
+	return DefaultProbeTimeout
+}
+
+func (t *TcpChecker) CheckHealth() []string {
+	t.pool.checkAll(t.Service.Config.BackendURLs(), t.states, t.timeout, t.GetInterval()/2,
+		t.probeKey, t.probe)
+	return t.states.healthy()
+}
+
+// probeKey identifies a probe for sharing: the resolved address plus everything that decides its outcome.
+func (t *TcpChecker) probeKey(backend string) string {
+	addr, err := backendAddr(backend, t.port)
+	if err != nil {
+		addr = backend
+	}
+	return fmt.Sprintf("tcp|%s|%q|%q|%s", addr, t.send, t.expect, t.timeout)
+}
+
+func (t *TcpChecker) probe(ctx context.Context, backend string) error {
+	addr, err := backendAddr(backend, t.port)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	if len(t.send) > 0 {
+		if _, err := conn.Write(t.send); err != nil {
+			return err
+		}
+	}
+	if len(t.expect) == 0 {
+		return nil
+	}
+
+	buf := make([]byte, 0, 512)
+	chunk := make([]byte, 512)
+	for len(buf) < maxExpectRead {
+		n, err := conn.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if bytes.Contains(buf, t.expect) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("expected reply not received: %w", err)
+		}
+	}
+	return errors.New("expected reply not found")
+}
+
+// backendAddr turns a backend URL into host:port, using port when set and otherwise the URL's port or the
+// default port of its scheme.
+func backendAddr(backend string, port int) (string, error) {
+	u, err := url.Parse(backend)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("backend %q is not a URL", backend)
+	}
+	if port != 0 {
+		return net.JoinHostPort(u.Hostname(), strconv.Itoa(port)), nil
+	}
+	if u.Port() != "" {
+		return u.Host, nil
+	}
+	switch u.Scheme {
+	case "https":
+		return net.JoinHostPort(u.Hostname(), "443"), nil
+	case "http":
+		return net.JoinHostPort(u.Hostname(), "80"), nil
+	}
+	return "", fmt.Errorf("backend %q has no port", backend)
+}
+
+func (t *TcpChecker) CheckAndUpdateBalancer() {
+	healthyBackends := t.CheckHealth()
+	t.Service.Balancer.SetHealthyBackends(healthyBackends)
+}
+
+func (t *TcpChecker) GetInterval() time.Duration {
+	return time.Duration(t.IntervalMs) * time.Millisecond
+}
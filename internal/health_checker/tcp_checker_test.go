@@ -0,0 +1,111 @@
+package health_checker
+
+import (
+	"bufio"
+	"github.com/aribhuiya/stormgate/internal/stormgate"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// startTCPServer accepts connections and answers every line with reply.
+func startTCPServer(t *testing.T, reply string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, err := bufio.NewReader(conn).ReadString('\n'); err == nil {
+					_, _ = conn.Write([]byte(reply))
+				}
+			}()
+		}
+	}()
+	return "http://" + ln.Addr().String()
+}
+
+// closedAddr returns the URL of a port nothing listens on.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	return "http://" + addr
+}
+
+func TestTcpChecker_CheckHealth(t *testing.T) {
+	up := startTCPServer(t, "+PONG\r\n")
+	wrongReply := startTCPServer(t, "-ERR\r\n")
+	down := closedAddr(t)
+
+	tests := []struct {
+		name string
+		cfg  utils.HealthConfig
+		want []string
+	}{
+		{name: "connect only", cfg: utils.HealthConfig{TimeoutMs: 500}, want: []string{up, wrongReply}},
+		{name: "send and expect", cfg: utils.HealthConfig{TimeoutMs: 500, Send: "PING\r\n", Expect: "PONG"}, want: []string{up}},
+		{name: "hex payloads", cfg: utils.HealthConfig{TimeoutMs: 500, SendHex: "50494e470a", ExpectHex: "2b504f4e47"}, want: []string{up}},
+		{name: "expect without reply times out", cfg: utils.HealthConfig{TimeoutMs: 100, Expect: "PONG"}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := stormgate.Service{Config: utils.Service{Backends: utils.BackendsFromURLs(up, wrongReply, down)}}
+			checker, err := NewTcpChecker(svc, &tt.cfg)
+			if err != nil {
+				t.Fatalf("NewTcpChecker() unexpected error: %v", err)
+			}
+			if got := checker.CheckHealth(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckHealth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTcpChecker_Validation(t *testing.T) {
+	for _, cfg := range []utils.HealthConfig{
+		{Send: "a", SendHex: "61"},
+		{ExpectHex: "zz"},
+		{Port: 70000},
+	} {
+		if _, err := NewTcpChecker(stormgate.Service{}, &cfg); err == nil {
+			t.Errorf("NewTcpChecker(%+v) expected error", cfg)
+		}
+	}
+}
+
+func TestBackendAddr(t *testing.T) {
+	tests := []struct {
+		backend string
+		port    int
+		want    string
+	}{
+		{backend: "http://db.internal:5432", want: "db.internal:5432"},
+		{backend: "http://db.internal", want: "db.internal:80"},
+		{backend: "https://db.internal/", want: "db.internal:443"},
+		{backend: "http://db.internal:8080", port: 9000, want: "db.internal:9000"},
+		{backend: "http://[::1]:8080", want: "[::1]:8080"},
+	}
+	for _, tt := range tests {
+		if got, err := backendAddr(tt.backend, tt.port); err != nil || got != tt.want {
+			t.Errorf("backendAddr(%q, %d) = %q, %v, want %q", tt.backend, tt.port, got, err, tt.want)
+		}
+	}
+	if _, err := backendAddr("db.internal:5432", 0); err == nil {
+		t.Errorf("expected error for a backend without scheme")
+	}
+}
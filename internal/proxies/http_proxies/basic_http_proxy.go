@@ -1,6 +1,7 @@
 package http_proxies
 
 import (
+	"github.com/aribhuiya/stormgate/internal/clientip"
 	"io"
 	"log"
 	"net/http"
@@ -21,32 +22,60 @@ func NewBasicProxy() *BasicProxy {
 	return &BasicProxy{&http.Client{Transport: transport}}
 }
 
-func (b BasicProxy) Forward(w http.ResponseWriter, req *http.Request, forwardingEndpoint *string) {
+func (b BasicProxy) Forward(w http.ResponseWriter, req *http.Request, forwardingEndpoint *string, observer RequestObserver) {
+	b.TryForward(w, req, forwardingEndpoint, observer, nil)
+}
+
+func (b BasicProxy) TryForward(w http.ResponseWriter, req *http.Request, forwardingEndpoint *string, observer RequestObserver,
+	decline func(RequestResult) bool) bool {
 	//fmt.Printf("Forwarding %s -> %s\n", req.URL, *forwardingEndpoint)
+	var result RequestResult
+	start := time.Now()
+	if observer != nil {
+		observer.RequestStarted(*forwardingEndpoint)
+		defer func() {
+			observer.RequestFinished(*forwardingEndpoint, result)
+		}()
+	}
 
 	outReq, err := http.NewRequest(req.Method, *forwardingEndpoint+req.URL.RequestURI(), req.Body)
 	if err != nil {
+		result.Err = err
 		http.Error(w, "Bad Gateway", http.StatusBadGateway)
-		return
+		return true
 	}
 
 	// Copy headers from incoming request
 	for k, v := range req.Header {
 		outReq.Header[k] = v
 	}
+	// Tell the backend who the client is. The address was resolved through the trusted proxies, so forwarding
+	// headers the client made up itself are not passed on.
+	client := clientip.FromRequest(req)
+	outReq.Header.Set("X-Forwarded-For", client)
+	outReq.Header.Set("X-Real-IP", client)
 
 	resp, err := b.client.Do(outReq)
+	result.Latency = time.Since(start)
 	if err != nil {
+		result.Err = err
+		if decline != nil && decline(result) {
+			return false
+		}
 		http.Error(w, "Backend unreachable", http.StatusBadGateway)
-		return
+		return true
 	}
+	result.StatusCode = resp.StatusCode
 	defer func(Body io.ReadCloser) {
 		Body.Close()
 	}(resp.Body)
+	if decline != nil && decline(result) {
+		return false
+	}
 
-	// Copy response headers
+	// Copy response headers, keeping any Set-Cookie the balancer already queued
 	for k, v := range resp.Header {
-		w.Header()[k] = v
+		w.Header()[k] = append(w.Header()[k], v...)
 	}
 	w.WriteHeader(resp.StatusCode)
 
@@ -54,6 +83,6 @@ func (b BasicProxy) Forward(w http.ResponseWriter, req *http.Request, forwarding
 	_, err = io.Copy(w, resp.Body)
 	if err != nil {
 		log.Println(err)
-		return
 	}
+	return true
 }
@@ -2,8 +2,32 @@ package http_proxies
 
 import (
 	"net/http"
+	"time"
 )
 
 type Proxy interface {
-	Forward(w http.ResponseWriter, req *http.Request, forwardingEndpoint *string)
+	Forward(w http.ResponseWriter, req *http.Request, forwardingEndpoint *string, observer RequestObserver)
+	// TryForward is Forward, except that when decline accepts the result of the attempt nothing is written to
+	// w and it returns false, so the caller can send the request elsewhere. decline may be nil.
+	TryForward(w http.ResponseWriter, req *http.Request, forwardingEndpoint *string, observer RequestObserver,
+		decline func(RequestResult) bool) bool
+}
+
+// RequestObserver is notified when a request to a backend starts and once its response body has been fully
+// streamed (or the attempt failed). Balancers implement it to track per-backend load and latency.
+type RequestObserver interface {
+	RequestStarted(backend string)
+	RequestFinished(backend string, result RequestResult)
+}
+
+// RequestResult describes how a backend handled a forwarded request.
+type RequestResult struct {
+	StatusCode int           // 0 when no response was received
+	Latency    time.Duration // time until the response headers arrived (or the attempt failed)
+	Err        error         // transport error talking to the backend
+}
+
+// Failed reports whether the backend errored, either at the transport level or with a 5xx response.
+func (r RequestResult) Failed() bool {
+	return r.Err != nil || r.StatusCode >= http.StatusInternalServerError
 }
@@ -67,7 +67,7 @@ func (r *HttpRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// TODO: Call goes to Balancer when ready
-	r.proxy.Forward(w, req, &route.Service.Backends[0])
+	r.proxy.Forward(w, req, &route.Service.Backends[0].URL, nil)
 
 	_, err = fmt.Fprintf(w, `{
   		"matched_path": "%s",
@@ -34,7 +34,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "auth-service",
 							PathPrefix: "/auth",
 							Strategy:   "round-robin",
-							Backends:   []string{"auth-1", "auth-2"},
+							Backends:   utils.BackendsFromURLs("auth-1", "auth-2"),
 						},
 					},
 				},
@@ -46,7 +46,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "auth-service",
 					PathPrefix: "/auth",
 					Strategy:   "round-robin",
-					Backends:   []string{"auth-1", "auth-2"},
+					Backends:   utils.BackendsFromURLs("auth-1", "auth-2"),
 				},
 			},
 			wantErr: false,
@@ -61,7 +61,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "api-v1-service",
 							PathPrefix: "/api/v1",
 							Strategy:   "least-connections",
-							Backends:   []string{"api-v1-1"},
+							Backends:   utils.BackendsFromURLs("api-v1-1"),
 						},
 					},
 				},
@@ -73,7 +73,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "api-v1-service",
 					PathPrefix: "/api/v1",
 					Strategy:   "least-connections",
-					Backends:   []string{"api-v1-1"},
+					Backends:   utils.BackendsFromURLs("api-v1-1"),
 				},
 			},
 			wantErr: false,
@@ -88,7 +88,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "users-service",
 							PathPrefix: "/api/v2/users",
 							Strategy:   "ip-hash",
-							Backends:   []string{"users-1"},
+							Backends:   utils.BackendsFromURLs("users-1"),
 						},
 					},
 				},
@@ -100,7 +100,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "users-service",
 					PathPrefix: "/api/v2/users",
 					Strategy:   "ip-hash",
-					Backends:   []string{"users-1"},
+					Backends:   utils.BackendsFromURLs("users-1"),
 				},
 			},
 			wantErr: false,
@@ -115,7 +115,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "profile-service",
 							PathPrefix: "/api/v1/users/profile",
 							Strategy:   "random",
-							Backends:   []string{"profile-1"},
+							Backends:   utils.BackendsFromURLs("profile-1"),
 						},
 					},
 				},
@@ -127,7 +127,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "profile-service",
 					PathPrefix: "/api/v1/users/profile",
 					Strategy:   "random",
-					Backends:   []string{"profile-1"},
+					Backends:   utils.BackendsFromURLs("profile-1"),
 				},
 			},
 			wantErr: false,
@@ -141,7 +141,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 						Name:       "default-service",
 						PathPrefix: "/",
 						Strategy:   "round-robin",
-						Backends:   []string{"default-1"},
+						Backends:   utils.BackendsFromURLs("default-1"),
 					},
 				},
 			},
@@ -152,7 +152,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "default-service",
 					PathPrefix: "/",
 					Strategy:   "round-robin",
-					Backends:   []string{"default-1"},
+					Backends:   utils.BackendsFromURLs("default-1"),
 				},
 			},
 			wantErr: false,
@@ -174,7 +174,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "auth-service",
 							PathPrefix: "/auth",
 							Strategy:   "round-robin",
-							Backends:   []string{"auth-1"},
+							Backends:   utils.BackendsFromURLs("auth-1"),
 						},
 					},
 				},
@@ -186,7 +186,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "auth-service",
 					PathPrefix: "/auth",
 					Strategy:   "round-robin",
-					Backends:   []string{"auth-1"},
+					Backends:   utils.BackendsFromURLs("auth-1"),
 				},
 			},
 			wantErr: false,
@@ -201,7 +201,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "v1-service",
 							PathPrefix: "/api/v1",
 							Strategy:   "ip-hash",
-							Backends:   []string{"v1-1"},
+							Backends:   utils.BackendsFromURLs("v1-1"),
 						},
 					},
 				},
@@ -213,7 +213,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "v1-service",
 					PathPrefix: "/api/v1",
 					Strategy:   "ip-hash",
-					Backends:   []string{"v1-1"},
+					Backends:   utils.BackendsFromURLs("v1-1"),
 				},
 			},
 			wantErr: false,
@@ -228,7 +228,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "product-service",
 							PathPrefix: "/api/v1/products",
 							Strategy:   "least-connections",
-							Backends:   []string{"prod-1"},
+							Backends:   utils.BackendsFromURLs("prod-1"),
 						},
 					},
 				},
@@ -240,7 +240,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "product-service",
 					PathPrefix: "/api/v1/products",
 					Strategy:   "least-connections",
-					Backends:   []string{"prod-1"},
+					Backends:   utils.BackendsFromURLs("prod-1"),
 				},
 			},
 			wantErr: false,
@@ -255,7 +255,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "product-service",
 							PathPrefix: "/api/v1/products",
 							Strategy:   "round-robin",
-							Backends:   []string{"prod-1"},
+							Backends:   utils.BackendsFromURLs("prod-1"),
 						},
 					},
 				},
@@ -267,7 +267,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "product-service",
 					PathPrefix: "/api/v1/products",
 					Strategy:   "round-robin",
-					Backends:   []string{"prod-1"},
+					Backends:   utils.BackendsFromURLs("prod-1"),
 				},
 			},
 			wantErr: false,
@@ -282,7 +282,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "v2-service",
 							PathPrefix: "/api/v2",
 							Strategy:   "random",
-							Backends:   []string{"v2-1"},
+							Backends:   utils.BackendsFromURLs("v2-1"),
 						},
 					},
 				},
@@ -300,7 +300,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 						Name:       "default-service",
 						PathPrefix: "/",
 						Strategy:   "round-robin",
-						Backends:   []string{"default-1"},
+						Backends:   utils.BackendsFromURLs("default-1"),
 					},
 				},
 			},
@@ -311,7 +311,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "default-service",
 					PathPrefix: "/",
 					Strategy:   "round-robin",
-					Backends:   []string{"default-1"},
+					Backends:   utils.BackendsFromURLs("default-1"),
 				},
 			},
 			wantErr: false,
@@ -326,7 +326,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "file-service",
 							PathPrefix: "/files/v2",
 							Strategy:   "round-robin",
-							Backends:   []string{"fs-1"},
+							Backends:   utils.BackendsFromURLs("fs-1"),
 						},
 					},
 				},
@@ -338,7 +338,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "file-service",
 					PathPrefix: "/files/v2",
 					Strategy:   "round-robin",
-					Backends:   []string{"fs-1"},
+					Backends:   utils.BackendsFromURLs("fs-1"),
 				},
 			},
 			wantErr: false,
@@ -353,7 +353,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "v2-service",
 							PathPrefix: "/api/v2",
 							Strategy:   "random",
-							Backends:   []string{"v2"},
+							Backends:   utils.BackendsFromURLs("v2"),
 						},
 					},
 				},
@@ -364,7 +364,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "payment-service",
 							PathPrefix: "/api/v2/payments",
 							Strategy:   "round-robin",
-							Backends:   []string{"pay1", "pay2"},
+							Backends:   utils.BackendsFromURLs("pay1", "pay2"),
 						},
 					},
 				},
@@ -376,7 +376,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "payment-service",
 					PathPrefix: "/api/v2/payments",
 					Strategy:   "round-robin",
-					Backends:   []string{"pay1", "pay2"},
+					Backends:   utils.BackendsFromURLs("pay1", "pay2"),
 				},
 			},
 			wantErr: false,
@@ -391,7 +391,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "shop-service",
 							PathPrefix: "/shop",
 							Strategy:   "ip-hash",
-							Backends:   []string{"shop-1"},
+							Backends:   utils.BackendsFromURLs("shop-1"),
 						},
 					},
 				},
@@ -402,7 +402,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "cart-service",
 							PathPrefix: "/shop/cart",
 							Strategy:   "round-robin",
-							Backends:   []string{"cart-1"},
+							Backends:   utils.BackendsFromURLs("cart-1"),
 						},
 					},
 				},
@@ -414,7 +414,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "cart-service",
 					PathPrefix: "/shop/cart",
 					Strategy:   "round-robin",
-					Backends:   []string{"cart-1"},
+					Backends:   utils.BackendsFromURLs("cart-1"),
 				},
 			},
 			wantErr: false,
@@ -429,7 +429,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "admin-service",
 							PathPrefix: "/admin",
 							Strategy:   "round-robin",
-							Backends:   []string{"admin-1"},
+							Backends:   utils.BackendsFromURLs("admin-1"),
 						},
 					},
 				},
@@ -448,7 +448,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "settings-service",
 							PathPrefix: "/settings",
 							Strategy:   "round-robin",
-							Backends:   []string{"s1"},
+							Backends:   utils.BackendsFromURLs("s1"),
 						},
 					},
 				},
@@ -460,7 +460,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "settings-service",
 					PathPrefix: "/settings",
 					Strategy:   "round-robin",
-					Backends:   []string{"s1"},
+					Backends:   utils.BackendsFromURLs("s1"),
 				},
 			},
 			wantErr: false,
@@ -475,7 +475,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "v4-service",
 							PathPrefix: "/api/v4",
 							Strategy:   "round-robin",
-							Backends:   []string{"v4-1"},
+							Backends:   utils.BackendsFromURLs("v4-1"),
 						},
 					},
 				},
@@ -494,7 +494,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "error-log-service",
 							PathPrefix: "/api/v3/logs/errors",
 							Strategy:   "least-connections",
-							Backends:   []string{"error-log-1"},
+							Backends:   utils.BackendsFromURLs("error-log-1"),
 						},
 					},
 				},
@@ -506,7 +506,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "error-log-service",
 					PathPrefix: "/api/v3/logs/errors",
 					Strategy:   "least-connections",
-					Backends:   []string{"error-log-1"},
+					Backends:   utils.BackendsFromURLs("error-log-1"),
 				},
 			},
 			wantErr: false,
@@ -520,7 +520,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 						Name:       "base-service",
 						PathPrefix: "/",
 						Strategy:   "round-robin",
-						Backends:   []string{"base"},
+						Backends:   utils.BackendsFromURLs("base"),
 					},
 				},
 			},
@@ -531,7 +531,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "base-service",
 					PathPrefix: "/",
 					Strategy:   "round-robin",
-					Backends:   []string{"base"},
+					Backends:   utils.BackendsFromURLs("base"),
 				},
 			},
 			wantErr: false,
@@ -546,7 +546,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "users-service",
 							PathPrefix: "/api/v1/users",
 							Strategy:   "round-robin",
-							Backends:   []string{"user1"},
+							Backends:   utils.BackendsFromURLs("user1"),
 						},
 					},
 				},
@@ -557,7 +557,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "admin-service",
 							PathPrefix: "/api/v1/users/admin",
 							Strategy:   "ip-hash",
-							Backends:   []string{"admin1"},
+							Backends:   utils.BackendsFromURLs("admin1"),
 						},
 					},
 				},
@@ -569,7 +569,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 					Name:       "admin-service",
 					PathPrefix: "/api/v1/users/admin",
 					Strategy:   "ip-hash",
-					Backends:   []string{"admin1"},
+					Backends:   utils.BackendsFromURLs("admin1"),
 				},
 			},
 			wantErr: false,
@@ -584,7 +584,7 @@ func TestHttpHybridRouting_Route(t *testing.T) {
 							Name:       "case-sensitive-service",
 							PathPrefix: "/Metrics/Prometheus",
 							Strategy:   "round-robin",
-							Backends:   []string{"prom-2"},
+							Backends:   utils.BackendsFromURLs("prom-2"),
 						},
 					},
 				},
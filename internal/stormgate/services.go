@@ -2,10 +2,14 @@ package stormgate
 
 import (
 	"github.com/aribhuiya/stormgate/internal/balancers"
+	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
 	"github.com/aribhuiya/stormgate/internal/utils"
 )
 
 type Service struct {
 	Config   utils.Service
 	Balancer balancers.Balancer
+	Observer http_proxies.RequestObserver // nil unless the balancer tracks in-flight requests or outliers
+	Fallback *Service                     // resolved fallback_service, nil when unset
+	fallback *fallbackLog
 }
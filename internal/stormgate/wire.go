@@ -1,21 +1,30 @@
 package stormgate
 
 import (
+	"errors"
 	"fmt"
 	"github.com/aribhuiya/stormgate/internal/balancers"
+	"github.com/aribhuiya/stormgate/internal/clientip"
+	"github.com/aribhuiya/stormgate/internal/cookies"
 	"github.com/aribhuiya/stormgate/internal/proxies/http_proxies"
 	"github.com/aribhuiya/stormgate/internal/routing_strategy"
 	"github.com/aribhuiya/stormgate/internal/utils"
 	"log"
 	"net/http"
+	"slices"
+	"sync/atomic"
 	"time"
 )
 
+// fallbackLogInterval is the most often a service logs that it is handing requests to its fallback.
+const fallbackLogInterval = 10 * time.Second
+
 type StormGate struct {
 	ServerConfig ServerConfig
 	Services     map[string]*Service
 	routing_strategy.RoutingStrategy
-	Proxy http_proxies.Proxy
+	Proxy    http_proxies.Proxy
+	ClientIP *clientip.Resolver
 }
 
 type ServerConfig struct {
@@ -34,7 +43,11 @@ func NewStormGate(config utils.Config) (*StormGate, error) {
 		ReadTimeOutMs:  serverConfig.ReadTimeOut,
 		WriteTimeOutMs: serverConfig.WriteTimeOut,
 	}
-	services, err := BuildServicesFromConfig(config.Services)
+	services, err := BuildServicesFromConfig(config.Services, config.Server.Zone)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := clientip.NewResolver(config.TrustedProxies)
 	if err != nil {
 		return nil, err
 	}
@@ -43,25 +56,140 @@ func NewStormGate(config utils.Config) (*StormGate, error) {
 		Services:        services,
 		RoutingStrategy: routing_strategy.CreateRoutingStrategy(config.Balancer.RoutingStrategy, &config.Services),
 		Proxy:           http_proxies.NewBasicProxy(),
+		ClientIP:        resolver,
 	}, nil
 }
 
-func BuildServicesFromConfig(services []utils.Service) (map[string]*Service, error) {
+func BuildServicesFromConfig(services []utils.Service, localZone string) (map[string]*Service, error) {
 	servicesMap := make(map[string]*Service)
 	for _, svcCfg := range services {
-		balancer, err := balancers.Create(svcCfg.Strategy, &svcCfg)
+		balancer, observer, err := buildBalancer(&svcCfg, localZone)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create Balancer for Service %s: %w", svcCfg.Name, err)
 		}
 		svc := &Service{
 			Config:   svcCfg,
 			Balancer: balancer,
+			Observer: observer,
+			fallback: &fallbackLog{},
 		}
 		servicesMap[svcCfg.PathPrefix] = svc
 	}
+	if err := resolveFallbacks(servicesMap); err != nil {
+		return nil, err
+	}
 	return servicesMap, nil
 }
 
+// resolveFallbacks links every service to its fallback_service and rejects unknown names and chains that loop
+// back on themselves.
+func resolveFallbacks(services map[string]*Service) error {
+	byName := make(map[string]*Service, len(services))
+	for _, svc := range services {
+		byName[svc.Config.Name] = svc
+	}
+	for _, svc := range services {
+		name := svc.Config.FallbackService
+		if name == "" {
+			if len(svc.Config.FallbackOnStatus) > 0 {
+				return fmt.Errorf("service %s sets fallback_on_status without fallback_service", svc.Config.Name)
+			}
+			continue
+		}
+		fallback, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("service %s falls back to unknown service %s", svc.Config.Name, name)
+		}
+		svc.Fallback = fallback
+	}
+	for _, svc := range services {
+		seen := map[*Service]bool{}
+		for next := svc; next != nil; next = next.Fallback {
+			if seen[next] {
+				return fmt.Errorf("fallback_service of %s loops back to %s", svc.Config.Name, next.Config.Name)
+			}
+			seen[next] = true
+		}
+	}
+	return nil
+}
+
+// buildBalancer creates the service's strategy over every backend it may route to, then layers sticky
+// sessions, the connection limits and the backend-set policies on top. The observer comes from the outermost
+// decorator that forwards it, as Filtered doesn't, and is wrapped by outlier detection when that is enabled.
+func buildBalancer(svcCfg *utils.Service, localZone string) (balancers.Balancer, http_proxies.RequestObserver, error) {
+	balancer, err := balancers.Create(svcCfg.Strategy, svcCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if svcCfg.StickyCookie != nil {
+		balancer, err = balancers.NewStickyCookie(balancer, svcCfg)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	balancer, err = balancers.NewConnLimited(balancer, svcCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	observer, _ := balancer.(http_proxies.RequestObserver)
+
+	var policies []balancers.Policy
+	if drain := balancers.NewDrainPolicy(svcCfg); drain != nil {
+		policies = append(policies, drain)
+	}
+	outliers, err := balancers.NewOutlierDetector(svcCfg, observer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if outliers != nil {
+		// Ejected backends count as unhealthy for the tier and zone decisions below.
+		observer = outliers
+		policies = append(policies, outliers)
+	}
+	if hasBackups(svcCfg) {
+		tiers, err := balancers.NewTierPolicy(svcCfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		policies = append(policies, tiers)
+	}
+	zones, err := balancers.NewZonePolicy(svcCfg, localZone)
+	if err != nil {
+		return nil, nil, err
+	}
+	if zones != nil {
+		policies = append(policies, zones)
+	}
+	panicMode, err := balancers.NewPanicPolicy(svcCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if panicMode != nil {
+		// Last, so panic mode judges the backends the tier and zone stages settled on and never overrides their
+		// failover. Drained backends stay out even in panic mode; ejected ones are let back in.
+		policies = append(policies, panicMode)
+	}
+
+	if len(policies) > 0 {
+		filtered := balancers.NewFiltered(balancer, svcCfg.BackendURLs(), policies...)
+		if outliers != nil {
+			outliers.OnChange(filtered.Refresh)
+		}
+		balancer = filtered
+	}
+	return balancer, observer, nil
+}
+
+func hasBackups(svcCfg *utils.Service) bool {
+	for _, b := range svcCfg.AllBackends() {
+		if b.Backup {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *StormGate) Serve() {
 	addr := fmt.Sprintf("%s:%d", s.ServerConfig.BindIp, s.ServerConfig.BindPort)
 	err := http.ListenAndServe(addr, s)
@@ -87,26 +215,76 @@ func (s *StormGate) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Resolve the client once for everything downstream that keys on it
+	req = req.WithContext(clientip.NewContext(req.Context(), s.ClientIP.Resolve(req)))
+
+	// Walk the fallback chain until a service serves the request. Chains are checked for loops at startup;
+	// tracking the visited services keeps a bad chain from spinning here regardless.
+	visited := map[*Service]bool{}
+	for service != nil && !visited[service] {
+		visited[service] = true
+		canFallBack := service.Fallback != nil && !visited[service.Fallback]
+		if s.serveWith(w, req, service, canFallBack) {
+			return
+		}
+		logFallback(service)
+		service = service.Fallback
+	}
+}
+
+// fallbackLog counts the requests a service hands to its fallback. Services are copied by value, so the
+// counters live behind a pointer.
+type fallbackLog struct {
+	count    atomic.Int64 // requests handed over since the last log line
+	loggedAt atomic.Int64 // unix nanoseconds of the last log line
+}
+
+// logFallback counts a request handed from service to its fallback. The first one is logged right away and
+// later ones are summed up at most once per fallbackLogInterval, so an outage doesn't flood the log.
+func logFallback(service *Service) {
+	service.fallback.count.Add(1)
+	now := time.Now().UnixNano()
+	last := service.fallback.loggedAt.Load()
+	if now-last < int64(fallbackLogInterval) || !service.fallback.loggedAt.CompareAndSwap(last, now) {
+		return
+	}
+	log.Printf("Service %s: %d request(s) fell back to %s since the last report",
+		service.Config.Name, service.fallback.count.Swap(0), service.Fallback.Config.Name)
+}
+
+// serveWith forwards req to one of service's backends. When canFallBack is set it returns false without
+// writing a response if no backend is healthy, or if the backend fails in one of the service's fallback
+// cases and the request had no body that would have to be replayed. Other balancer errors, such as every
+// backend being at max_connections, are answered here rather than passed to the fallback.
+func (s *StormGate) serveWith(w http.ResponseWriter, req *http.Request, service *Service, canFallBack bool) bool {
 	// Use Balancer
+	ctx, jar := cookies.NewContext(req.Context())
+	req = req.WithContext(ctx)
 	forwardPath, err := service.Balancer.PickBackend(req)
 	if err != nil {
+		if canFallBack && errors.Is(err, utils.ErrNoHealthyBackends) {
+			return false
+		}
 		http.Error(w, fmt.Sprintf("E-1 Internal Server Error %s", err), http.StatusInternalServerError)
-		return
+		return true
 	}
 
-	val := req.Context().Value("inject_cookie")
-	if cookieVal, ok := val.(string); ok {
-		path := service.Config.PathPrefix
-		println("Setting cookie to " + cookieVal)
-		http.SetCookie(w, &http.Cookie{
-			Name:     "stormgate-id",
-			Value:    cookieVal,
-			Path:     path,
-			HttpOnly: true,
-			Expires:  time.Now().Add(365 * 24 * time.Hour),
-		})
+	if !canFallBack || (req.Body != nil && req.Body != http.NoBody) {
+		jar.Write(w)
+		s.Proxy.Forward(w, req, &forwardPath, service.Observer)
+		return true
 	}
-
-	s.Proxy.Forward(w, req, &forwardPath)
-
+	decline := func(result http_proxies.RequestResult) bool {
+		if result.Err != nil {
+			return true
+		}
+		return slices.Contains(service.Config.FallbackOnStatus, result.StatusCode)
+	}
+	jar.Write(w)
+	if !s.Proxy.TryForward(w, req, &forwardPath, service.Observer, decline) {
+		// The fallback service answers instead; don't pin the client to this service's backend.
+		w.Header().Del("Set-Cookie")
+		return false
+	}
+	return true
 }
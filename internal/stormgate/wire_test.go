@@ -0,0 +1,200 @@
+package stormgate
+
+import (
+	"bytes"
+	"github.com/aribhuiya/stormgate/internal/utils"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func startBackend(t *testing.T, status int, body string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func newTestStormGate(t *testing.T, services ...utils.Service) *StormGate {
+	t.Helper()
+	sg, err := NewStormGate(utils.Config{Services: services})
+	if err != nil {
+		t.Fatalf("NewStormGate() unexpected error: %v", err)
+	}
+	return sg
+}
+
+func serve(sg *StormGate, method, path, body string) (int, string) {
+	var reqBody io.Reader
+	if body != "" {
+		reqBody = strings.NewReader(body)
+	}
+	rec := httptest.NewRecorder()
+	sg.ServeHTTP(rec, httptest.NewRequest(method, path, reqBody))
+	return rec.Code, rec.Body.String()
+}
+
+func TestServeHTTP_FallbackService(t *testing.T) {
+	primary := startBackend(t, http.StatusServiceUnavailable, "primary down")
+	degraded := startBackend(t, http.StatusOK, "degraded")
+
+	sg := newTestStormGate(t,
+		utils.Service{
+			Name: "api", PathPrefix: "/api/", Strategy: "round_robin", Backends: utils.BackendsFromURLs(primary),
+			FallbackService: "static", FallbackOnStatus: []int{http.StatusServiceUnavailable},
+		},
+		utils.Service{Name: "static", PathPrefix: "/static/", Strategy: "round_robin", Backends: utils.BackendsFromURLs(degraded)},
+	)
+
+	if code, body := serve(sg, http.MethodGet, "/api/orders", ""); code != http.StatusOK || body != "degraded" {
+		t.Errorf("GET on failing status = %d %q, want the fallback's response", code, body)
+	}
+	// A request body can't be replayed, so the backend's response is passed through.
+	if code, body := serve(sg, http.MethodPost, "/api/orders", "payload"); code != http.StatusServiceUnavailable || body != "primary down" {
+		t.Errorf("POST on failing status = %d %q, want the primary's response", code, body)
+	}
+
+	sg.Services["/api/"].Balancer.SetHealthyBackends(nil)
+	if code, body := serve(sg, http.MethodPost, "/api/orders", "payload"); code != http.StatusOK || body != "degraded" {
+		t.Errorf("no healthy backends = %d %q, want the fallback's response", code, body)
+	}
+
+	sg.Services["/static/"].Balancer.SetHealthyBackends(nil)
+	if code, _ := serve(sg, http.MethodGet, "/api/orders", ""); code != http.StatusInternalServerError {
+		t.Errorf("no healthy backends anywhere = %d, want 500", code)
+	}
+}
+
+func TestServeHTTP_FallbackLogIsRateLimited(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	degraded := startBackend(t, http.StatusOK, "degraded")
+	sg := newTestStormGate(t,
+		utils.Service{
+			Name: "api", PathPrefix: "/api/", Strategy: "round_robin", Backends: utils.BackendsFromURLs("http://localhost:9"),
+			FallbackService: "static",
+		},
+		utils.Service{Name: "static", PathPrefix: "/static/", Strategy: "round_robin", Backends: utils.BackendsFromURLs(degraded)},
+	)
+	sg.Services["/api/"].Balancer.SetHealthyBackends(nil)
+
+	for i := 0; i < 5; i++ {
+		if code, _ := serve(sg, http.MethodGet, "/api/orders", ""); code != http.StatusOK {
+			t.Fatalf("GET = %d, want the fallback's 200", code)
+		}
+	}
+	if lines := strings.Count(logs.String(), "fell back to static"); lines != 1 {
+		t.Errorf("logged %d fallback lines for 5 requests, want 1:\n%s", lines, logs.String())
+	}
+}
+
+func TestServeHTTP_FallsBackOnlyWithoutHealthyBackends(t *testing.T) {
+	primary := startBackend(t, http.StatusOK, "primary")
+	degraded := startBackend(t, http.StatusOK, "degraded")
+	sg := newTestStormGate(t,
+		utils.Service{
+			Name: "api", PathPrefix: "/api/", Strategy: "round_robin",
+			Backends: []utils.Backend{{URL: primary, MaxConnections: 1}}, FallbackService: "static",
+		},
+		utils.Service{Name: "static", PathPrefix: "/static/", Strategy: "round_robin", Backends: utils.BackendsFromURLs(degraded)},
+	)
+
+	// A backend at max_connections is a limit, not an outage: the client gets the error, not the fallback.
+	sg.Services["/api/"].Observer.RequestStarted(primary)
+	if code, body := serve(sg, http.MethodGet, "/api/orders", ""); code != http.StatusInternalServerError {
+		t.Errorf("GET with every backend at max_connections = %d %q, want 500", code, body)
+	}
+}
+
+func TestServeHTTP_ForwardsResolvedClientIP(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("X-Forwarded-For")+" "+r.Header.Get("X-Real-IP"))
+	}))
+	t.Cleanup(backend.Close)
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		want           string
+	}{
+		{name: "untrusted peer", want: "192.0.2.1 192.0.2.1"},
+		{name: "trusted peer", trustedProxies: []string{"192.0.2.0/24"}, want: "203.0.113.9 203.0.113.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sg, err := NewStormGate(utils.Config{
+				TrustedProxies: tt.trustedProxies,
+				Services: []utils.Service{
+					{Name: "api", PathPrefix: "/api/", Strategy: "round_robin", Backends: utils.BackendsFromURLs(backend.URL)},
+				},
+			})
+			if err != nil {
+				t.Fatalf("NewStormGate() unexpected error: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/orders", nil) // RemoteAddr 192.0.2.1:1234
+			req.Header.Set("X-Forwarded-For", "198.51.100.7, 203.0.113.9")
+			rec := httptest.NewRecorder()
+			sg.ServeHTTP(rec, req)
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("backend saw X-Forwarded-For / X-Real-IP %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildBalancer_PanicAfterFailover(t *testing.T) {
+	balancer, _, err := buildBalancer(&utils.Service{
+		Name: "api", Strategy: "round_robin", PanicThreshold: 0.5,
+		Backends:       utils.BackendsFromURLs("http://p1", "http://p2", "http://p3"),
+		BackupBackends: utils.BackendsFromURLs("http://b1", "http://b2"),
+	}, "")
+	if err != nil {
+		t.Fatalf("buildBalancer() unexpected error: %v", err)
+	}
+
+	balancer.SetHealthyBackends([]string{"http://b1", "http://b2"})
+	for i := 0; i < 10; i++ {
+		if got, _ := balancer.PickBackend(nil); got != "http://b1" && got != "http://b2" {
+			t.Fatalf("PickBackend() = %v, want a healthy backup", got)
+		}
+	}
+}
+
+func TestResolveFallbacks_Validation(t *testing.T) {
+	backends := utils.BackendsFromURLs("http://localhost:9001")
+	tests := []struct {
+		name     string
+		services []utils.Service
+	}{
+		{name: "unknown service", services: []utils.Service{
+			{Name: "a", PathPrefix: "/a/", Strategy: "round_robin", Backends: backends, FallbackService: "missing"},
+		}},
+		{name: "self", services: []utils.Service{
+			{Name: "a", PathPrefix: "/a/", Strategy: "round_robin", Backends: backends, FallbackService: "a"},
+		}},
+		{name: "loop", services: []utils.Service{
+			{Name: "a", PathPrefix: "/a/", Strategy: "round_robin", Backends: backends, FallbackService: "b"},
+			{Name: "b", PathPrefix: "/b/", Strategy: "round_robin", Backends: backends, FallbackService: "c"},
+			{Name: "c", PathPrefix: "/c/", Strategy: "round_robin", Backends: backends, FallbackService: "a"},
+		}},
+		{name: "statuses without service", services: []utils.Service{
+			{Name: "a", PathPrefix: "/a/", Strategy: "round_robin", Backends: backends, FallbackOnStatus: []int{503}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := BuildServicesFromConfig(tt.services, ""); err == nil {
+				t.Errorf("BuildServicesFromConfig() expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Backend is a single upstream of a service. In YAML it is either a plain URL string (the legacy form)
+// or an object carrying per-backend settings.
+type Backend struct {
+	URL            string            `yaml:"url" json:"url"`
+	Weight         int32             `yaml:"weight" json:"weight,omitempty"`                   // 0 means unset (1, or the legacy strategy_config.weights)
+	Zone           string            `yaml:"zone" json:"zone,omitempty"`                       // availability zone, for zone-aware routing
+	MaxConnections int64             `yaml:"max_connections" json:"max_connections,omitempty"` // in-flight request cap, 0 is unlimited
+	Labels         map[string]string `yaml:"labels" json:"labels,omitempty"`
+	Backup         bool              `yaml:"backup" json:"backup,omitempty"` // only used when too few primaries are healthy
+	Drain          bool              `yaml:"drain" json:"drain,omitempty"`   // receives no new requests
+}
+
+func (b *Backend) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&b.URL)
+	}
+	type plain Backend // drops the method so Decode doesn't recurse
+	if err := node.Decode((*plain)(b)); err != nil {
+		return err
+	}
+	if b.URL == "" {
+		return errors.New("backend is missing url")
+	}
+	return nil
+}
+
+// BackendsFromURLs builds plain backends, equivalent to listing the URLs as strings in the config.
+func BackendsFromURLs(urls ...string) []Backend {
+	backends := make([]Backend, len(urls))
+	for i, url := range urls {
+		backends[i] = Backend{URL: url}
+	}
+	return backends
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"testing"
+)
+
+func TestBackend_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      []Backend
+		expectErr bool
+	}{
+		{
+			name:  "legacy strings",
+			input: `["http://a:9001", "http://b:9002"]`,
+			want:  BackendsFromURLs("http://a:9001", "http://b:9002"),
+		},
+		{
+			name: "objects mixed with strings",
+			input: `
+- http://a:9001
+- url: http://b:9002
+  weight: 3
+  zone: zone-b
+  max_connections: 100
+  labels: {rack: r2}
+  backup: true
+  drain: true
+`,
+			want: []Backend{
+				{URL: "http://a:9001"},
+				{URL: "http://b:9002", Weight: 3, Zone: "zone-b", MaxConnections: 100,
+					Labels: map[string]string{"rack": "r2"}, Backup: true, Drain: true},
+			},
+		},
+		{name: "object without url", input: `[{weight: 2}]`, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Backend
+			err := yaml.Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("Unmarshal() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_AllBackends_LegacyFields(t *testing.T) {
+	service := &Service{
+		Backends:       []Backend{{URL: "A"}, {URL: "B", Zone: "zone-x"}},
+		BackupBackends: BackendsFromURLs("C"),
+		BackendZones:   map[string]string{"A": "zone-a", "B": "zone-b", "C": "zone-c"},
+	}
+	want := []Backend{
+		{URL: "A", Zone: "zone-a"},
+		{URL: "B", Zone: "zone-x"},
+		{URL: "C", Zone: "zone-c", Backup: true},
+	}
+	if got := service.AllBackends(); !reflect.DeepEqual(got, want) {
+		t.Errorf("AllBackends() = %+v, want %+v", got, want)
+	}
+	if got := service.BackendURLs(); !reflect.DeepEqual(got, []string{"A", "B", "C"}) {
+		t.Errorf("BackendURLs() = %v", got)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	tests := []struct {
+		name      string
+		service   Service
+		want      []int32
+		expectErr bool
+	}{
+		{name: "no weights", service: Service{Backends: BackendsFromURLs("A", "B")}},
+		{
+			name:    "backend weights default to 1",
+			service: Service{Backends: []Backend{{URL: "A", Weight: 3}, {URL: "B"}}},
+			want:    []int32{3, 1},
+		},
+		{
+			name: "legacy strategy_config weights",
+			service: Service{Backends: BackendsFromURLs("A", "B"),
+				StrategyConfig: map[string]any{"weights": []interface{}{2, 5}}},
+			want: []int32{2, 5},
+		},
+		{
+			name: "both forms",
+			service: Service{Backends: []Backend{{URL: "A", Weight: 3}, {URL: "B"}},
+				StrategyConfig: map[string]any{"weights": []interface{}{2, 5}}},
+			expectErr: true,
+		},
+		{name: "negative weight", service: Service{Backends: []Backend{{URL: "A", Weight: -1}}}, expectErr: true},
+		{
+			name: "legacy count mismatch",
+			service: Service{Backends: BackendsFromURLs("A", "B"),
+				StrategyConfig: map[string]any{"weights": []interface{}{2}}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWeights(&tt.service)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("ParseWeights() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseWeights() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v3"
+	"net"
+	"net/url"
 	"os"
+	"strings"
 )
 
 type Server struct {
@@ -10,6 +14,7 @@ type Server struct {
 	BindPort     int32  `yaml:"bind_port"`
 	ReadTimeOut  int64  `yaml:"read_time_out"`
 	WriteTimeOut int64  `yaml:"write_time_out"`
+	Zone         string `yaml:"zone"` // availability zone Stormgate runs in, for zone-aware routing
 }
 
 type Balancer struct {
@@ -21,20 +26,188 @@ type Service struct {
 	PathPrefix     string         `yaml:"path_prefix"`
 	Strategy       string         `yaml:"strategy"`
 	StrategyConfig map[string]any `yaml:"strategy_config"`
-	Backends       []string       `yaml:"backends"`
-	Health         *HealthConfig  `yaml:"health"`
+	Backends       []Backend      `yaml:"backends"`
+	// BackupBackends and BackendZones are the legacy forms of Backend.Backup and Backend.Zone.
+	BackupBackends     []Backend               `yaml:"backup_backends"`
+	BackendZones       map[string]string       `yaml:"backend_zones"`
+	FailoverThreshold  float64                 `yaml:"failover_threshold"`   // healthy primary fraction below which backups take traffic
+	LocalZoneThreshold float64                 `yaml:"local_zone_threshold"` // healthy local fraction below which other zones take traffic
+	PanicThreshold     float64                 `yaml:"panic_threshold"`      // healthy fraction below which every backend takes traffic
+	Health             *HealthConfig           `yaml:"health"`
+	SlowStart          *SlowStartConfig        `yaml:"slow_start"`
+	StickyCookie       *StickyCookieConfig     `yaml:"sticky_cookie"`
+	OutlierDetection   *OutlierDetectionConfig `yaml:"outlier_detection"`
+	// FallbackService names the service that takes the requests this one can't serve: when no backend is
+	// available, or when a bodiless request fails to connect or gets one of FallbackOnStatus (e.g. 502, 503).
+	FallbackService  string `yaml:"fallback_service"`
+	FallbackOnStatus []int  `yaml:"fallback_on_status"`
+}
+
+// AllBackends returns every backend of the service with the legacy forms folded in: the backup_backends list
+// is appended with Backup set and backend_zones fills in missing zones.
+func (s *Service) AllBackends() []Backend {
+	all := make([]Backend, 0, len(s.Backends)+len(s.BackupBackends))
+	all = append(all, s.Backends...)
+	for _, b := range s.BackupBackends {
+		b.Backup = true
+		all = append(all, b)
+	}
+	for i := range all {
+		if all[i].Zone == "" {
+			all[i].Zone = s.BackendZones[all[i].URL]
+		}
+	}
+	return all
+}
+
+// BackendURLs returns the URLs of AllBackends, the identity balancers and health checks work with.
+func (s *Service) BackendURLs() []string {
+	all := s.AllBackends()
+	urls := make([]string, len(all))
+	for i, b := range all {
+		urls[i] = b.URL
+	}
+	return urls
+}
+
+// Admin configures the optional admin API listener; it is disabled when BindPort is 0.
+type Admin struct {
+	BindIp   string `yaml:"bind_ip"`
+	BindPort int32  `yaml:"bind_port"`
 }
 
 type Config struct {
 	Server   Server    `yaml:"server"`
 	Services []Service `yaml:"services"`
 	Balancer Balancer  `yaml:"balancer"`
+	Admin    Admin     `yaml:"admin"`
+	// TrustedProxies lists the CIDRs whose X-Forwarded-For / Forwarded headers are believed.
+	TrustedProxies []string           `yaml:"trusted_proxies"`
+	HealthChecks   HealthChecksConfig `yaml:"health_checks"`
+}
+
+// HealthChecksConfig tunes the probe scheduler shared by all services.
+type HealthChecksConfig struct {
+	// Workers bounds how many probes run at once across all services (default 32).
+	Workers int `yaml:"workers"`
+	// JitterPercent randomly spreads each check interval by up to ±this percentage (default 10, -1 disables).
+	JitterPercent int         `yaml:"jitter_percent"`
+	Hooks         HooksConfig `yaml:"hooks"`
+}
+
+// MaxWebhookTimeoutMs bounds webhook_timeout_ms; transitions queue up behind a slow webhook.
+const MaxWebhookTimeoutMs = 30000
+
+// HooksConfig selects what happens when a backend changes health state.
+type HooksConfig struct {
+	Log              *bool  `yaml:"log"`                // log a line per transition (default true)
+	WebhookURL       string `yaml:"webhook_url"`        // POST every transition as JSON to this URL
+	WebhookTimeoutMs int64  `yaml:"webhook_timeout_ms"` // default 2000, at most MaxWebhookTimeoutMs
+	// The webhook is meant for a local agent, so its host must be loopback unless remote URLs are allowed.
+	WebhookAllowRemote bool `yaml:"webhook_allow_remote"`
+}
+
+// Validate checks the webhook settings, if a webhook is configured.
+func (h HooksConfig) Validate() error {
+	if h.WebhookURL == "" {
+		return nil
+	}
+	u, err := url.Parse(h.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook_url %q must be an http(s) URL", h.WebhookURL)
+	}
+	if !h.WebhookAllowRemote && !isLoopbackHost(u.Hostname()) {
+		return fmt.Errorf("webhook_url %q is not a loopback address; set webhook_allow_remote to post elsewhere", h.WebhookURL)
+	}
+	if h.WebhookTimeoutMs < 0 || h.WebhookTimeoutMs > MaxWebhookTimeoutMs {
+		return fmt.Errorf("webhook_timeout_ms must be between 0 and %d (0 means the default)", MaxWebhookTimeoutMs)
+	}
+	return nil
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
 type HealthConfig struct {
 	Endpoint  string `yaml:"health-endpoint"`
 	Type      string `yaml:"type"`
 	Frequency int64  `yaml:"frequency"`
+	TimeoutMs int64  `yaml:"timeout_ms"`
+	// Consecutive probe results needed to change a backend's state (default 1), and the state backends start
+	// in before their first probes: "healthy" (default) or "unhealthy".
+	HealthyThreshold   int    `yaml:"healthy_threshold"`
+	UnhealthyThreshold int    `yaml:"unhealthy_threshold"`
+	InitialState       string `yaml:"initial_state"`
+	// HistorySize is how many recent results per backend the admin API keeps (default 32).
+	HistorySize int `yaml:"history_size"`
+	// HTTP checks: request shape and what the response must look like to count as healthy.
+	Method         string            `yaml:"method"`
+	Headers        map[string]string `yaml:"headers"`
+	Host           string            `yaml:"host"`
+	ExpectedStatus []string          `yaml:"expected_status"` // codes or ranges like "200-299"; default 200
+	BodyContains   string            `yaml:"body_contains"`
+	BodyRegex      string            `yaml:"body_regex"`
+	JSONPath       string            `yaml:"json_path"`  // dotted path into a JSON body, e.g. "checks.db.status"
+	JSONValue      string            `yaml:"json_value"` // the value found at json_path must equal this
+	MaxBodyBytes   int64             `yaml:"max_body_bytes"`
+	// TCP and gRPC checks: probe this port instead of the backend's.
+	Port int `yaml:"port"`
+	// TCP checks: optionally send a payload and expect a reply containing a pattern. The _hex forms take the
+	// bytes hex-encoded.
+	Send      string `yaml:"send"`
+	SendHex   string `yaml:"send_hex"`
+	Expect    string `yaml:"expect"`
+	ExpectHex string `yaml:"expect_hex"`
+	// gRPC checks: the service name sent to grpc.health.v1.Health/Check (empty asks about the whole server)
+	// and whether to skip verifying the certificate of https backends.
+	GRPCService   string `yaml:"grpc_service"`
+	TLSSkipVerify bool   `yaml:"tls_skip_verify"`
+}
+
+// StickyCookieConfig pins each client to the backend that served its first request through a signed cookie.
+// When no secret is configured a random one is generated, so pins don't survive restarts.
+type StickyCookieConfig struct {
+	CookieConfig `yaml:",inline"`
+	Secret       string `yaml:"secret"` // single signing key, used before any in secrets
+}
+
+// CookieConfig sets the attributes of a cookie Stormgate issues.
+type CookieConfig struct {
+	Name     string   `yaml:"name"`
+	Path     string   `yaml:"path"` // defaults to the service path_prefix
+	Domain   string   `yaml:"domain"`
+	MaxAgeS  int64    `yaml:"max_age_s"` // lifetime in seconds
+	Secure   bool     `yaml:"secure"`
+	SameSite string   `yaml:"same_site"` // lax, strict or none
+	Secrets  []string `yaml:"secrets"`   // HMAC keys for signing the value; the first signs, all verify
+}
+
+// SlowStartConfig ramps the effective weight of a backend that (re)joins the healthy set from
+// MinWeightPercent of its weight to the full weight over DurationMs.
+type SlowStartConfig struct {
+	DurationMs       int64    `yaml:"duration_ms"`
+	MinWeightPercent *float64 `yaml:"min_weight_percent"` // default 10; 0 starts the ramp from (almost) nothing
+	// Aggression shapes the ramp as (elapsed/duration)^(1/aggression); 1 is linear, larger values ramp faster early.
+	Aggression float64 `yaml:"aggression"`
+}
+
+// OutlierDetectionConfig ejects backends that fail live traffic, independently of the active health checks.
+// A backend is ejected after ConsecutiveFailures 5xx responses or connection errors in a row, or when its
+// failure rate over an interval exceeds the rate of the whole pool by FailureRateMargin. An ejection lasts
+// BaseEjectionMs, doubling with every recent ejection of the same backend up to MaxEjectionMs.
+type OutlierDetectionConfig struct {
+	ConsecutiveFailures int     `yaml:"consecutive_failures"` // default 5
+	FailureRateMargin   float64 `yaml:"failure_rate_margin"`  // e.g. 0.2 = 20 points above the pool; 0 disables
+	MinRequests         int64   `yaml:"min_requests"`         // requests an interval needs for rate ejection (default 20)
+	IntervalMs          int64   `yaml:"interval_ms"`          // rate evaluation interval (default 10000)
+	BaseEjectionMs      int64   `yaml:"base_ejection_ms"`     // default 30000
+	MaxEjectionMs       int64   `yaml:"max_ejection_ms"`      // default 300000
+	MaxEjectionPercent  float64 `yaml:"max_ejection_percent"` // share of the pool ejectable at once (default 10, min one backend)
 }
 
 func LoadConfig(path string) (Config, error) {
@@ -46,5 +219,8 @@ func LoadConfig(path string) (Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return cfg, err
 	}
+	if err := cfg.HealthChecks.Hooks.Validate(); err != nil {
+		return cfg, fmt.Errorf("health_checks.hooks: %w", err)
+	}
 	return cfg, nil
 }
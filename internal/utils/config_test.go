@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHooksConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         HooksConfig
+		expectError bool
+	}{
+		{name: "no webhook", cfg: HooksConfig{}},
+		{name: "localhost", cfg: HooksConfig{WebhookURL: "http://localhost:9900/hook"}},
+		{name: "loopback IPv4", cfg: HooksConfig{WebhookURL: "https://127.0.0.1/hook", WebhookTimeoutMs: MaxWebhookTimeoutMs}},
+		{name: "loopback IPv6", cfg: HooksConfig{WebhookURL: "http://[::1]:9900/hook"}},
+		{name: "remote allowed", cfg: HooksConfig{WebhookURL: "https://alerts.example.com/hook", WebhookAllowRemote: true}},
+		{name: "remote", cfg: HooksConfig{WebhookURL: "https://alerts.example.com/hook"}, expectError: true},
+		{name: "no scheme", cfg: HooksConfig{WebhookURL: "localhost:9000/hook"}, expectError: true},
+		{name: "not http", cfg: HooksConfig{WebhookURL: "ftp://localhost/hook"}, expectError: true},
+		{name: "negative timeout", cfg: HooksConfig{WebhookURL: "http://localhost/hook", WebhookTimeoutMs: -1}, expectError: true},
+		{name: "timeout too long", cfg: HooksConfig{WebhookURL: "http://localhost/hook", WebhookTimeoutMs: MaxWebhookTimeoutMs + 1}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.expectError {
+				t.Errorf("Validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_ValidatesHooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := []byte("health_checks:\n  hooks:\n    webhook_url: \"https://alerts.example.com/hook\"\n")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Errorf("LoadConfig() expected error for a remote webhook_url")
+	}
+}
@@ -0,0 +1,6 @@
+package utils
+
+import "errors"
+
+// ErrNoHealthyBackends is returned by balancers when no healthy backend is left to pick from.
+var ErrNoHealthyBackends = errors.New("no healthy backends available")
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParseWeights returns one weight per backend, in BackendURLs order. Weights come either from the backend
+// objects (unset weights default to 1) or from the legacy strategy_config.weights list, which must line up 1:1
+// with the backends. It returns nil without error when the service does not configure weights.
+func ParseWeights(service *Service) ([]int32, error) {
+	backends := service.AllBackends()
+	raw, exists := service.StrategyConfig["weights"]
+
+	structured := false
+	for _, b := range backends {
+		if b.Weight != 0 {
+			structured = true
+			break
+		}
+	}
+	if structured {
+		if exists {
+			return nil, errors.New("weights are set on the backends and in strategy_config.weights — use one or the other")
+		}
+		weights := make([]int32, len(backends))
+		for i, b := range backends {
+			switch {
+			case b.Weight == 0:
+				weights[i] = 1
+			case b.Weight < 0:
+				return nil, fmt.Errorf("weight of backend %s is %d — must be a positive integer", b.URL, b.Weight)
+			default:
+				weights[i] = b.Weight
+			}
+		}
+		return weights, nil
+	}
+
+	if !exists {
+		return nil, nil
+	}
+	rawWeights, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.New("weights missing or malformed")
+	}
+	weights := make([]int32, len(rawWeights))
+	for i, w := range rawWeights {
+		intVal, ok := w.(int)
+		if !ok {
+			return nil, fmt.Errorf("weight at index %d is not an int", i)
+		}
+		weights[i] = int32(intVal)
+	}
+
+	if err := validateWeights(service.BackendURLs(), weights); err != nil {
+		return nil, err
+	}
+	return weights, nil
+}
+
+func validateWeights(backends []string, weights []int32) error {
+	if len(backends) != len(weights) {
+		return fmt.Errorf("number of weights (%d) does not match number of backends (%d)", len(weights), len(backends))
+	}
+
+	for i, w := range weights {
+		if w <= 0 {
+			return fmt.Errorf("weight at index %d is %d — must be a positive integer", i, w)
+		}
+	}
+	return nil
+}